@@ -24,33 +24,107 @@ import (
 
 // Analysis is a dump of information all about a list of Streams.
 type Analysis struct {
-	Everything AnalyzedDocuments // title is ""
+	Everything AnalyzedDocuments // title is "Everything"
 
-	EverythingByKey []AnalyzedDocuments // title is title + scope
+	Groupings []Grouping // in the order they were asked for; the default groupings if none were
 
-	EverythingByTag []AnalyzedDocuments // title is tag
+	EverythingByConfidence []AnalyzedDocuments // yes-or-no predictions only, whatever groupings were asked for, since calibration needs them
 
-	EverythingByConfidence []AnalyzedDocuments
+	Conditional AnalyzedDocuments // predictions with a “given” key
+}
+
+// By returns the groups of the grouping with the given name, like “tag” or “tag,year”, or nil if there isn’t one.
+func (a Analysis) By(name string) []AnalyzedDocuments {
+	for _, g := range a.Groupings {
+		if g.Name == name {
+			return g.Groups
+		}
+	}
+	return nil
+}
+
+// InformativeGroupings returns every grouping except the ones that only have one group with every prediction in it, since those say nothing Everything doesn’t.
+func (a Analysis) InformativeGroupings() []Grouping {
+	ret := make([]Grouping, 0, len(a.Groupings))
+	for _, g := range a.Groupings {
+		if len(g.Groups) == 0 || (len(g.Groups) == 1 && len(g.Groups[0].Documents) == len(a.Everything.Documents)) {
+			continue
+		}
+		ret = append(ret, g)
+	}
+	return ret
 }
 
 // An AnalyzedDocuments contains both an AnalysisUnit and a slice of PredictionDocument.
 type AnalyzedDocuments struct {
 	AnalysisUnit AnalysisUnit
 	Documents    []streams.PredictionDocument
+
+	// Labels are the labels every document has, one for each of the grouping’s dimensions, if this is one of a Grouping’s groups.
+	Labels []string
+}
+
+// A Forecast is what’s left of a scored prediction once its claim is stripped away: how likely it was thought to be and whether it happened.
+type Forecast struct {
+	Probability float64 // on [0, 1]
+	Happened    bool
+}
+
+// probabilityOfOutcome returns the probability the forecast gave to what actually happened.
+func (f Forecast) probabilityOfOutcome() float64 {
+	if f.Happened {
+		return f.Probability
+	}
+	return 1 - f.Probability
+}
+
+// A CategoricalForecast is what’s left of a scored multiple-choice prediction: how likely each outcome was thought to be and which one happened.
+type CategoricalForecast struct {
+	Probabilities []float64 // each on [0, 1], in the order the outcomes were written in
+	Happened      int       // index into Probabilities
+}
+
+// An IntervalForecast is what’s left of a scored numeric-range prediction: its interval, how likely the interval was thought to cover the actual value, and the actual value.
+type IntervalForecast struct {
+	Low, High   float64
+	Probability float64 // on (0, 1)
+	Actual      float64
+}
+
+// covered returns true if the actual value is within the interval, inclusive.
+func (f IntervalForecast) covered() bool {
+	return f.Low <= f.Actual && f.Actual <= f.High
+}
+
+// intervalScore returns the interval score of the forecast: the width of the interval, plus a penalty proportional to how far outside the interval the actual value fell.
+func (f IntervalForecast) intervalScore() float64 {
+	α := 1 - f.Probability
+	ret := f.High - f.Low
+	if f.Actual < f.Low {
+		ret += 2 / α * (f.Low - f.Actual)
+	}
+	if f.Actual > f.High {
+		ret += 2 / α * (f.Actual - f.High)
+	}
+	return ret
 }
 
 // AnalysisUnit provides information on a subset of an Analysis.
 type AnalysisUnit struct {
 	Title              string
 	SquaredDifferences []float64 // (f_t-o_t)^2
+	Forecasts          []Forecast
+
+	CategoricalForecasts []CategoricalForecast // multiple-choice predictions, which aren’t in SquaredDifferences or Forecasts
+	IntervalForecasts    []IntervalForecast    // numeric-range predictions, which aren’t either
 
 	Called int //   predicted correctly
 	Missed int //   predicted incorrectly
 
 	Ongoing  int //   no “happened” value
-	Excluded int //   has “cause for exclusion” key with value
+	Excluded int //   has “cause for exclusion” key with value, or its condition failed
 
-	Unscorable int // lacks claim, lacks confidence, or both
+	Unscorable int // lacks claim, lacks confidence, or both; says an outcome happened that it doesn’t have; or has only half an interval
 }
 
 // Total returns the sum of the scored items, the unscored items, and the unscorable items.
@@ -59,6 +133,20 @@ func (au *AnalysisUnit) Total() int { return au.Scored() + au.Unscored() + au.Un
 // Scored returns the sum of the called items and the missed items.
 func (au *AnalysisUnit) Scored() int { return au.Called + au.Missed }
 
+// YesOrNoCalled returns how many of the scored yes-or-no predictions were called. Called and Missed count every kind of prediction.
+func (au *AnalysisUnit) YesOrNoCalled() int {
+	ret := 0
+	for _, f := range au.Forecasts {
+		if f.Happened {
+			ret++
+		}
+	}
+	return ret
+}
+
+// YesOrNoMissed returns how many of the scored yes-or-no predictions were missed.
+func (au *AnalysisUnit) YesOrNoMissed() int { return len(au.Forecasts) - au.YesOrNoCalled() }
+
 // Unscored returns the sum of the ongoing and the excluded items.
 func (au *AnalysisUnit) Unscored() int { return au.Ongoing + au.Excluded }
 
@@ -118,6 +206,21 @@ func (au *AnalysisUnit) Add(confidence float64, happened bool) {
 	ret := math.Pow(confidence-outcome, 2.0)
 
 	au.SquaredDifferences = append(au.SquaredDifferences, ret)
+	au.Forecasts = append(au.Forecasts, Forecast{Probability: confidence, Happened: happened})
+}
+
+// AddCategorical adds a multiple-choice prediction to the unit.
+//
+// probabilities must each be on [0, 1], and happened must be the index of the one that happened.
+func (au *AnalysisUnit) AddCategorical(probabilities []float64, happened int) {
+	au.CategoricalForecasts = append(au.CategoricalForecasts, CategoricalForecast{Probabilities: probabilities, Happened: happened})
+}
+
+// AddInterval adds a numeric-range prediction to the unit.
+//
+// probability must be on (0, 1).
+func (au *AnalysisUnit) AddInterval(low, high, probability, actual float64) {
+	au.IntervalForecasts = append(au.IntervalForecasts, IntervalForecast{Low: low, High: high, Probability: probability, Actual: actual})
 }
 
 // BrierScore calculates the Brier score of added squared differences.
@@ -135,6 +238,217 @@ func (au *AnalysisUnit) BrierScore() float64 {
 	return sum / float64(len(au.SquaredDifferences))
 }
 
+// HitRate calculates the fraction, on [0, 1], of added predictions that happened.
+//
+// Returns NaN if no predictions have been added.
+func (au *AnalysisUnit) HitRate() float64 {
+	if len(au.Forecasts) == 0 {
+		return math.NaN()
+	}
+	var happened float64
+	for _, f := range au.Forecasts {
+		if f.Happened {
+			happened++
+		}
+	}
+
+	return happened / float64(len(au.Forecasts))
+}
+
+// z95 is the z-score that leaves 2.5% in each tail of a standard normal distribution.
+const z95 = 1.959964
+
+// WilsonInterval calculates the 95% Wilson score interval, on [0, 1], around the receiver’s HitRate.
+//
+// The fewer predictions there are, the wider the interval. Returns NaNs if no predictions have been added.
+func (au *AnalysisUnit) WilsonInterval() (low, high float64) {
+	n := float64(len(au.Forecasts))
+	if n == 0 {
+		return math.NaN(), math.NaN()
+	}
+
+	p := au.HitRate()
+	z2 := z95 * z95
+
+	center := (p + z2/(2*n)) / (1 + z2/n)
+	halfWidth := z95 / (1 + z2/n) * math.Sqrt(p*(1-p)/n+z2/(4*n*n))
+
+	return math.Max(0, center-halfWidth), math.Min(1, center+halfWidth)
+}
+
+// baselineBrierScore is the Brier score of someone who says everything has a 50/50 chance of happening.
+const baselineBrierScore = 0.25
+
+// BrierSkillScore calculates how much better the Brier score is than that of someone who gives everything a 50% chance.
+//
+// 1 is perfect, 0 is no better than a coin flip, and anything negative is worse than a coin flip. Returns NaN if no predictions have been added.
+func (au *AnalysisUnit) BrierSkillScore() float64 {
+	return 1 - au.BrierScore()/baselineBrierScore
+}
+
+// LogScore calculates the mean natural logarithm of the probabilities given to what actually happened.
+//
+// 0 is perfect and a coin flip gets about −0.693. Confidently predicting something that doesn’t come to pass is punished much more harshly than it is by the Brier score; a 0% or 100% miss scores negative infinity. Log loss is the negation of this. Returns NaN if no predictions have been added.
+func (au *AnalysisUnit) LogScore() float64 {
+	if len(au.Forecasts) == 0 {
+		return math.NaN()
+	}
+	var sum float64
+	for _, f := range au.Forecasts {
+		sum += math.Log(f.probabilityOfOutcome())
+	}
+
+	return sum / float64(len(au.Forecasts))
+}
+
+// SphericalScore calculates the mean spherical score of added predictions.
+//
+// The spherical score of a prediction is the probability given to what happened divided by the length of the whole probability vector. It ranges from 0 (worst) to 1 (best), and a coin flip gets about 0.707. Returns NaN if no predictions have been added.
+func (au *AnalysisUnit) SphericalScore() float64 {
+	if len(au.Forecasts) == 0 {
+		return math.NaN()
+	}
+	var sum float64
+	for _, f := range au.Forecasts {
+		norm := math.Sqrt(f.Probability*f.Probability + (1-f.Probability)*(1-f.Probability))
+		sum += f.probabilityOfOutcome() / norm
+	}
+
+	return sum / float64(len(au.Forecasts))
+}
+
+// CategoricalBrierScore calculates the multi-class Brier score of added multiple-choice predictions: the mean, over predictions, of the sum of squared differences between each outcome’s probability and whether it happened.
+//
+// It ranges from 0 (best) to 2 (worst). It isn’t comparable with BrierScore, which only counts one squared difference per yes-or-no prediction. Returns NaN if no multiple-choice predictions have been added.
+func (au *AnalysisUnit) CategoricalBrierScore() float64 {
+	if len(au.CategoricalForecasts) == 0 {
+		return math.NaN()
+	}
+	var sum float64
+	for _, cf := range au.CategoricalForecasts {
+		for i, p := range cf.Probabilities {
+			outcome := 0.0
+			if i == cf.Happened {
+				outcome = 1.0
+			}
+			sum += math.Pow(p-outcome, 2.0)
+		}
+	}
+
+	return sum / float64(len(au.CategoricalForecasts))
+}
+
+// CategoricalLogScore calculates the mean natural logarithm of the probabilities given to the outcomes of multiple-choice predictions that actually happened.
+//
+// Like LogScore, 0 is perfect. Returns NaN if no multiple-choice predictions have been added.
+func (au *AnalysisUnit) CategoricalLogScore() float64 {
+	if len(au.CategoricalForecasts) == 0 {
+		return math.NaN()
+	}
+	var sum float64
+	for _, cf := range au.CategoricalForecasts {
+		sum += math.Log(cf.Probabilities[cf.Happened])
+	}
+
+	return sum / float64(len(au.CategoricalForecasts))
+}
+
+// IntervalScore calculates the mean interval score of added numeric-range predictions.
+//
+// An interval’s score is its width plus, if the actual value fell outside it, 2/α times the distance by which it missed, where α is the chance the interval was thought to miss. Lower is better. Scores are in whatever units the predictions were in, so they’re only comparable between predictions of similar quantities. Returns NaN if no numeric-range predictions have been added.
+func (au *AnalysisUnit) IntervalScore() float64 {
+	if len(au.IntervalForecasts) == 0 {
+		return math.NaN()
+	}
+	var sum float64
+	for _, f := range au.IntervalForecasts {
+		sum += f.intervalScore()
+	}
+
+	return sum / float64(len(au.IntervalForecasts))
+}
+
+// IntervalCoverage calculates the fraction, on [0, 1], of added numeric-range predictions whose actual values fell within their intervals.
+//
+// A well-calibrated group of 80% intervals covers about 80% of actual values. Returns NaN if no numeric-range predictions have been added.
+func (au *AnalysisUnit) IntervalCoverage() float64 {
+	if len(au.IntervalForecasts) == 0 {
+		return math.NaN()
+	}
+	var covered float64
+	for _, f := range au.IntervalForecasts {
+		if f.covered() {
+			covered++
+		}
+	}
+
+	return covered / float64(len(au.IntervalForecasts))
+}
+
+// A BrierDecomposition is the Murphy decomposition of a Brier score: the Brier score equals Reliability − Resolution + Uncertainty.
+type BrierDecomposition struct {
+	// Reliability measures how far each confidence level’s hit rate is from the confidence level itself. 0 is perfectly calibrated; lower is better.
+	Reliability float64
+
+	// Resolution measures how far each confidence level’s hit rate is from the overall hit rate. Predictions that don’t distinguish between likely and unlikely things have no resolution; higher is better.
+	Resolution float64
+
+	// Uncertainty depends only on how often things happened, not on any prediction. It’s highest (.25) when half of everything happens.
+	Uncertainty float64
+}
+
+// BrierScore returns the Brier score that the receiver decomposes.
+func (bd BrierDecomposition) BrierScore() float64 {
+	return bd.Reliability - bd.Resolution + bd.Uncertainty
+}
+
+// BrierDecomposition calculates the Murphy decomposition of the Brier score of everything, using the predictions grouped by confidence level.
+//
+// All fields are NaN if nothing has been scored.
+func (a Analysis) BrierDecomposition() BrierDecomposition {
+	var n, happened float64
+	for _, ads := range a.EverythingByConfidence {
+		for _, f := range ads.AnalysisUnit.Forecasts {
+			n++
+			if f.Happened {
+				happened++
+			}
+		}
+	}
+
+	if n == 0 {
+		return BrierDecomposition{math.NaN(), math.NaN(), math.NaN()}
+	}
+
+	baseRate := happened / n
+
+	var ret BrierDecomposition
+	for _, ads := range a.EverythingByConfidence {
+		fs := ads.AnalysisUnit.Forecasts
+		if len(fs) == 0 {
+			continue
+		}
+
+		var bucketHappened float64
+		for _, f := range fs {
+			if f.Happened {
+				bucketHappened++
+			}
+		}
+
+		nk := float64(len(fs))
+		hitRate := bucketHappened / nk
+		ret.Reliability += nk * math.Pow(fs[0].Probability-hitRate, 2)
+		ret.Resolution += nk * math.Pow(hitRate-baseRate, 2)
+	}
+
+	ret.Reliability /= n
+	ret.Resolution /= n
+	ret.Uncertainty = baseRate * (1 - baseRate)
+
+	return ret
+}
+
 // Confidence returns the confidence level of an AnalyzedDocuments if they all have the same confidence level, or nil otherwise.
 func (ads *AnalyzedDocuments) Confidence() *float64 {
 	const ε = 0.0001
@@ -156,33 +470,29 @@ func (ads *AnalyzedDocuments) Confidence() *float64 {
 }
 
 // Analyze calculates Brier scores for the given streams.
-func Analyze(sts []streams.Stream) Analysis {
+func Analyze(sts []streams.Stream, options ...Option) Analysis {
+	o := newAnalysisOptions(options)
 	ret := Analysis{}
 
+	sts = streams.WithForecast(sts, o.forecastMode)
+
 	ret.Everything = Only(sts, streams.Everything)
 	ret.Everything.AnalysisUnit.Title = "Everything"
 
-	tagsUsed := streams.TagsUsed(sts)
-	for _, tag := range tagsUsed {
-		ds := Only(sts, streams.MatchingTag(tag))
-		ds.AnalysisUnit.Title = fmt.Sprintf("Tag: %s", tag)
-		ret.EverythingByTag = append(ret.EverythingByTag, ds)
-
+	groupings := o.groupings
+	if len(groupings) == 0 {
+		for _, name := range o.defaultGroupings {
+			groupings = append(groupings, []Dimension{dimensions[name]})
+		}
 	}
-
-	keysUsed := streams.KeysUsed(sts)
-	for _, key := range keysUsed {
-		ds := Only(sts, streams.MatchingKey(key))
-		ds.AnalysisUnit.Title = key
-		ret.EverythingByKey = append(ret.EverythingByKey, ds)
+	for _, dims := range groupings {
+		ret.Groupings = append(ret.Groupings, group(sts, o.configure(dims)))
 	}
 
-	confidencesUsed := streams.ConfidencesUsed(sts)
-	for _, confidence := range confidencesUsed {
-		ds := Only(sts, streams.MatchingConfidence(confidence))
-		ds.AnalysisUnit.Title = fmt.Sprintf("At the %.0f%% confidence level", confidence)
-		ret.EverythingByConfidence = append(ret.EverythingByConfidence, ds)
-	}
+	ret.EverythingByConfidence = group(sts, []Dimension{dimensions["confidence"]}).Groups
+
+	ret.Conditional = Only(sts, streams.Conditional)
+	ret.Conditional.AnalysisUnit.Title = "Conditional predictions"
 
 	return ret
 }
@@ -199,16 +509,33 @@ func Only(sts []streams.Stream, f streams.Filter) AnalyzedDocuments {
 
 			ret.Documents = append(ret.Documents, p)
 
+			if p.ConditionFailed {
+				ret.AnalysisUnit.Excluded++
+				continue
+			}
+
+			if p.IsCategorical() {
+				ret.AnalysisUnit.addCategoricalDocument(p)
+				continue
+			}
+
+			if p.IsInterval() {
+				ret.AnalysisUnit.addIntervalDocument(p)
+				continue
+			}
+
 			if p.Claim == "" || p.Confidence == nil {
 				ret.AnalysisUnit.Unscorable++
 				continue
 			}
 
+			// A cause for exclusion wins even over a “happened” value, just as it does in ShouldExclude.
+			if p.CauseForExclusion != "" {
+				ret.AnalysisUnit.Excluded++
+				continue
+			}
+
 			if p.Happened == nil {
-				if p.CauseForExclusion != "" {
-					ret.AnalysisUnit.Excluded++
-					continue
-				}
 				ret.AnalysisUnit.Ongoing++
 				continue
 			}
@@ -223,10 +550,63 @@ func Only(sts []streams.Stream, f streams.Filter) AnalyzedDocuments {
 		}
 	}
 
-	if len(ret.AnalysisUnit.SquaredDifferences) != ret.AnalysisUnit.Scored() {
+	au := ret.AnalysisUnit
+	if len(au.SquaredDifferences)+len(au.CategoricalForecasts)+len(au.IntervalForecasts) != au.Scored() {
 		fmt.Fprintln(os.Stderr, "logic error: squared differences and scored items differ")
 		os.Exit(4)
 	}
 
 	return ret
 }
+
+// addCategoricalDocument counts and, if possible, scores a multiple-choice prediction.
+func (au *AnalysisUnit) addCategoricalDocument(p streams.PredictionDocument) {
+	happened := p.Outcomes.Index(p.HappenedOutcome)
+
+	switch {
+	case p.Claim == "" || (p.IsResolved() && happened < 0):
+		au.Unscorable++
+		return
+	case p.CauseForExclusion != "":
+		au.Excluded++
+		return
+	case !p.IsResolved():
+		au.Ongoing++
+		return
+	}
+
+	probabilities := make([]float64, 0, len(p.Outcomes))
+	for _, o := range p.Outcomes {
+		probabilities = append(probabilities, o.Confidence/100.0)
+	}
+
+	au.AddCategorical(probabilities, happened)
+	if p.Outcomes.IsFavorite(p.HappenedOutcome) {
+		au.Called++
+	} else {
+		au.Missed++
+	}
+}
+
+// addIntervalDocument counts and, if possible, scores a numeric-range prediction.
+func (au *AnalysisUnit) addIntervalDocument(p streams.PredictionDocument) {
+	switch {
+	// A 100% interval that misses would get an infinite interval score.
+	case p.Claim == "" || p.Confidence == nil || p.Low == nil || p.High == nil || *p.Confidence >= 100:
+		au.Unscorable++
+		return
+	case p.CauseForExclusion != "":
+		au.Excluded++
+		return
+	case !p.IsResolved():
+		au.Ongoing++
+		return
+	}
+
+	au.AddInterval(*p.Low, *p.High, *p.Confidence/100.0, *p.Actual)
+	if p.Covered() {
+		au.Called++
+	} else {
+		au.Missed++
+	}
+}
@@ -0,0 +1,316 @@
+// © 2019 Nathan Galt
+//
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analyze
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/adiabatic/predictions/streams"
+)
+
+func TestScoringRules(t *testing.T) {
+	const ε = 0.0001
+
+	coinFlipper := AnalysisUnit{}
+	coinFlipper.Add(.5, true)
+	coinFlipper.Add(.5, false)
+
+	assert.InDelta(t, .25, coinFlipper.BrierScore(), ε)
+	assert.InDelta(t, 0, coinFlipper.BrierSkillScore(), ε)
+	assert.InDelta(t, math.Log(.5), coinFlipper.LogScore(), ε)
+	assert.InDelta(t, math.Sqrt(.5), coinFlipper.SphericalScore(), ε)
+
+	confidentlyWrong := AnalysisUnit{}
+	confidentlyWrong.Add(.9, false)
+
+	assert.InDelta(t, .81, confidentlyWrong.BrierScore(), ε)
+	assert.InDelta(t, 1-.81/.25, confidentlyWrong.BrierSkillScore(), ε)
+	assert.InDelta(t, math.Log(.1), confidentlyWrong.LogScore(), ε)
+	assert.InDelta(t, .1/math.Sqrt(.82), confidentlyWrong.SphericalScore(), ε)
+
+	empty := AnalysisUnit{}
+	assert.True(t, math.IsNaN(empty.LogScore()))
+	assert.True(t, math.IsNaN(empty.SphericalScore()))
+}
+
+func TestBrierDecomposition(t *testing.T) {
+	const ε = 0.0001
+
+	a := Analysis{}
+	for _, bucket := range []struct {
+		probability float64
+		outcomes    []bool
+	}{
+		{.9, []bool{true, true, true, false}},
+		{.6, []bool{true, false, false}},
+		{.2, []bool{false, false, true}},
+	} {
+		ads := AnalyzedDocuments{}
+		for _, outcome := range bucket.outcomes {
+			ads.AnalysisUnit.Add(bucket.probability, outcome)
+		}
+		a.EverythingByConfidence = append(a.EverythingByConfidence, ads)
+	}
+
+	everything := AnalysisUnit{}
+	for _, ads := range a.EverythingByConfidence {
+		for _, f := range ads.AnalysisUnit.Forecasts {
+			everything.Add(f.Probability, f.Happened)
+		}
+	}
+
+	bd := a.BrierDecomposition()
+	assert.InDelta(t, everything.BrierScore(), bd.BrierScore(), ε)
+	assert.InDelta(t, 0.5*0.5, bd.Uncertainty, ε)
+	assert.True(t, bd.Reliability >= 0)
+	assert.True(t, bd.Resolution >= 0)
+
+	assert.True(t, math.IsNaN(Analysis{}.BrierDecomposition().Reliability))
+}
+
+func TestWilsonInterval(t *testing.T) {
+	const ε = 0.0001
+
+	au := AnalysisUnit{}
+	for i := 0; i < 10; i++ {
+		au.Add(.8, i < 8)
+	}
+
+	assert.InDelta(t, .8, au.HitRate(), ε)
+
+	low, high := au.WilsonInterval()
+	assert.InDelta(t, 0.4902, low, ε)
+	assert.InDelta(t, 0.9433, high, ε)
+
+	few := AnalysisUnit{}
+	few.Add(.8, true)
+	few.Add(.8, true)
+	fewLow, fewHigh := few.WilsonInterval()
+	assert.True(t, fewHigh-fewLow > high-low, "fewer predictions should mean a wider interval")
+	assert.InDelta(t, 1, fewHigh, ε)
+}
+
+func TestCategoricalScores(t *testing.T) {
+	const ε = 0.0001
+
+	au := AnalysisUnit{}
+	au.AddCategorical([]float64{.5, .3, .2}, 1)
+
+	assert.InDelta(t, .25+.49+.04, au.CategoricalBrierScore(), ε)
+	assert.InDelta(t, math.Log(.3), au.CategoricalLogScore(), ε)
+	assert.True(t, math.IsNaN(au.BrierScore()), "multiple-choice predictions shouldn’t count toward the yes-or-no Brier score")
+
+	empty := AnalysisUnit{}
+	assert.True(t, math.IsNaN(empty.CategoricalBrierScore()))
+	assert.True(t, math.IsNaN(empty.CategoricalLogScore()))
+}
+
+func TestIntervalScores(t *testing.T) {
+	const ε = 0.0001
+
+	au := AnalysisUnit{}
+	au.AddInterval(2, 3, .8, 2.5)
+	au.AddInterval(2, 3, .8, 4)
+
+	// The miss is 1 above the high, and α is .2, so it’s penalized by 2/.2 × 1.
+	assert.InDelta(t, (1+(1+10))/2.0, au.IntervalScore(), ε)
+	assert.InDelta(t, .5, au.IntervalCoverage(), ε)
+	assert.True(t, math.IsNaN(au.BrierScore()), "numeric-range predictions shouldn’t count toward the yes-or-no Brier score")
+
+	empty := AnalysisUnit{}
+	assert.True(t, math.IsNaN(empty.IntervalScore()))
+	assert.True(t, math.IsNaN(empty.IntervalCoverage()))
+
+	st, err := streams.FromReader(strings.NewReader(`---
+title: Certain
+---
+claim: Commute in minutes
+low: 20
+high: 40
+confidence: 100
+actual: 55
+`))
+	require.NoError(t, err)
+	certain := Analyze([]streams.Stream{st}).Everything.AnalysisUnit
+	assert.Equal(t, 1, certain.Unscorable, "a 100% interval that misses can’t be given a finite interval score")
+	assert.True(t, math.IsNaN(certain.IntervalScore()))
+}
+
+const taggedStream = `---
+title: Tagged
+tag order: [work]
+---
+claim: I will sleep in on Saturday
+confidence: 90
+tags: [zebra]
+happened: false
+---
+claim: I will finish the report
+confidence: 80
+tags: [work, zebra, Apple]
+happened: true
+---
+claim: I will eat a mango
+confidence: 99
+tags: [mango]
+happened: true
+---
+claim: I will fix the fence
+confidence: 70
+tags: [zebra, Apple]
+happened: true
+`
+
+func TestTagOrder(t *testing.T) {
+	st, err := streams.FromReader(strings.NewReader(taggedStream))
+	require.NoError(t, err)
+	sts := []streams.Stream{st}
+
+	assert.Equal(t, []string{"work", "Apple", "mango", "zebra"}, Tags(sts))
+	assert.Equal(t, []string{"work", "zebra", "Apple", "mango"}, Tags(sts, SortUnlistedTagsBy(ByCount)))
+	assert.Equal(t, []string{"work", "mango", "Apple", "zebra"}, Tags(sts, SortUnlistedTagsBy(ByBrierScore)))
+
+	a := Analyze(sts, SortUnlistedTagsBy(ByBrierScore))
+	titles := make([]string, 0, len(a.By("tag")))
+	for _, ads := range a.By("tag") {
+		titles = append(titles, ads.AnalysisUnit.Title)
+	}
+	assert.Equal(t, []string{"Tag: work", "Tag: mango", "Tag: Apple", "Tag: zebra"}, titles)
+
+	_, err = ParseTagSort("shoe size")
+	assert.Error(t, err)
+}
+
+const nestedStream = `---
+title: Nested
+---
+claim: The senate will pass the bill
+confidence: 60
+tags: [politics/us]
+happened: true
+---
+claim: I will be on call over the holidays
+confidence: 30
+tags: [work/oncall]
+happened: false
+---
+claim: The summit will end with a treaty
+confidence: 20
+tags: [politics/intl]
+happened: false
+`
+
+func TestNestedTags(t *testing.T) {
+	const ε = 0.0001
+
+	st, err := streams.FromReader(strings.NewReader(nestedStream))
+	require.NoError(t, err)
+
+	a := Analyze([]streams.Stream{st})
+	tags := make([]string, 0, len(a.By("tag")))
+	for _, ads := range a.By("tag") {
+		tags = append(tags, ads.Labels[0])
+	}
+	assert.Equal(t, []string{"politics", "politics/intl", "politics/us", "work", "work/oncall"}, tags)
+
+	politics := a.By("tag")[0]
+	assert.Len(t, politics.Documents, 2)
+	assert.Equal(t, 1, politics.AnalysisUnit.Called)
+	assert.Equal(t, 1, politics.AnalysisUnit.Missed)
+	assert.InDelta(t, (.4*.4+.2*.2)/2, politics.AnalysisUnit.BrierScore(), ε)
+}
+
+const yearsStream = `---
+title: Years
+---
+claim: I will ship the app
+confidence: 80
+tags: [work]
+made on: 2018-03-01
+happened: true
+---
+claim: I will ship the sequel
+confidence: 70
+tags: [work]
+made on: 2019-03-01
+happened: false
+---
+claim: I will run a marathon
+confidence: 60
+tags: [health]
+made on: 2019-04-01
+happened: true
+`
+
+func TestGroupBy(t *testing.T) {
+	st, err := streams.FromReader(strings.NewReader(yearsStream))
+	require.NoError(t, err)
+	sts := []streams.Stream{st}
+
+	dims, err := ParseGrouping("tag, year")
+	require.NoError(t, err)
+
+	a := Analyze(sts, GroupBy(dims...))
+	require.Len(t, a.Groupings, 1)
+	assert.Equal(t, "tag,year", a.Groupings[0].Name)
+
+	titles := make([]string, 0)
+	for _, ads := range a.By("tag,year") {
+		titles = append(titles, ads.AnalysisUnit.Title)
+	}
+	assert.Equal(t, []string{"Tag: health × Made in 2019", "Tag: work × Made in 2018", "Tag: work × Made in 2019"}, titles)
+	assert.Equal(t, []string{"work", "2019"}, a.By("tag,year")[2].Labels)
+	assert.Equal(t, 1, a.By("tag,year")[2].AnalysisUnit.Missed)
+
+	// Every prediction here is from the same file, so grouping by key says nothing new.
+	a = Analyze(sts, GroupBy(dimensions["key"]), GroupBy(dimensions["year"]))
+	require.Len(t, a.InformativeGroupings(), 1)
+	assert.Equal(t, "year", a.InformativeGroupings()[0].Name)
+
+	_, err = ParseGrouping("tag,decade")
+	assert.Error(t, err)
+}
+
+func TestExcludedPredictionsHaveNoConfidenceGroup(t *testing.T) {
+	st, err := streams.FromReader(strings.NewReader(`---
+title: Excluded
+---
+claim: I will fix the fence
+confidence: 70
+happened: true
+---
+claim: The meeting will run long
+confidence: 20
+cause for exclusion: it was canceled
+happened: false
+`))
+	require.NoError(t, err)
+
+	a := Analyze([]streams.Stream{st})
+	require.Len(t, a.By("confidence"), 1)
+	assert.Equal(t, "At the 70% confidence level", a.By("confidence")[0].AnalysisUnit.Title)
+
+	// The excluded prediction says what happened, but it’s still excluded, just as ShouldExclude says.
+	au := a.Everything.AnalysisUnit
+	assert.True(t, st.Predictions[1].ShouldExclude())
+	assert.Equal(t, 1, au.Excluded)
+	assert.Equal(t, 1, au.Scored())
+	assert.Len(t, au.Forecasts, 1)
+}
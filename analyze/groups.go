@@ -0,0 +1,275 @@
+// © 2019 Nathan Galt
+//
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analyze
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adiabatic/predictions/streams"
+)
+
+// A Dimension is something predictions can be grouped by, like their tags or the year they were made in.
+type Dimension struct {
+	// Name is what the dimension is called, like “tag”.
+	Name string
+
+	// Labels returns the labels of every group a prediction goes in. A prediction can go in more than one group, or in none at all.
+	Labels func(d streams.PredictionDocument) []string
+
+	// Title returns the title of the group with the given label, like “Tag: work”. If it’s nil, the label is the title.
+	Title func(label string) string
+
+	// Order puts the labels used in the given streams in the order their groups go in. If it’s nil, groups go in the order their labels were first seen in.
+	Order func(sts []streams.Stream, labels []string) []string
+
+	// configure, if it isn’t nil, returns the dimension as it should be, given the analysis options.
+	configure func(o analysisOptions) Dimension
+}
+
+// A Grouping is predictions grouped by one or more dimensions. With more than one, like tag × year, there’s a group for every combination of labels that at least one prediction has.
+type Grouping struct {
+	Name   string // its dimensions’ names, separated by commas, like “tag,year”
+	Groups []AnalyzedDocuments
+}
+
+// dimensions are the built-in dimensions, by name.
+var dimensions = map[string]Dimension{
+	"key": {
+		Name: "key",
+		Labels: func(d streams.PredictionDocument) []string {
+			if d.Parent == nil {
+				return nil
+			}
+			return []string{d.Parent.Metadata.Title + " " + d.Parent.Metadata.Scope}
+		},
+	},
+
+	"tag": {
+		Name:   "tag",
+		Labels: tagLabels,
+		Title:  tagTitle,
+		configure: func(o analysisOptions) Dimension {
+			return Dimension{
+				Name:   "tag",
+				Labels: tagLabels,
+				Title:  tagTitle,
+				Order:  func(sts []streams.Stream, _ []string) []string { return o.orderedTags(sts) },
+			}
+		},
+	},
+
+	"confidence": {
+		Name: "confidence",
+		Labels: func(d streams.PredictionDocument) []string {
+			if d.ShouldExclude() || !d.IsBinary() || d.Confidence == nil {
+				return nil
+			}
+			return []string{formatConfidence(*d.Confidence)}
+		},
+		Title: func(label string) string { return fmt.Sprintf("At the %s%% confidence level", roundConfidence(label)) },
+		Order: numericOrder,
+	},
+
+	"interval-confidence": {
+		Name: "interval-confidence",
+		Labels: func(d streams.PredictionDocument) []string {
+			if d.ShouldExclude() || !d.IsInterval() || d.Confidence == nil {
+				return nil
+			}
+			return []string{formatConfidence(*d.Confidence)}
+		},
+		Title: func(label string) string {
+			return fmt.Sprintf("Intervals at the %s%% confidence level", roundConfidence(label))
+		},
+		Order: numericOrder,
+	},
+
+	"resolved": {
+		Name: "resolved",
+		configure: func(o analysisOptions) Dimension {
+			p := o.resolutionPeriod
+			return Dimension{
+				Name: "resolved",
+				Labels: func(d streams.PredictionDocument) []string {
+					if d.ResolvedOn == nil {
+						return nil
+					}
+					return []string{p.Start(*d.ResolvedOn).Format(labelDateFormat)}
+				},
+				Title: func(label string) string {
+					start, _ := time.Parse(labelDateFormat, label)
+					return "Resolved in " + p.Format(start)
+				},
+				// Dates in labelDateFormat sort the same way as strings as they do as dates.
+				Order: func(_ []streams.Stream, labels []string) []string {
+					sort.Strings(labels)
+					return labels
+				},
+			}
+		},
+	},
+
+	"year": {
+		Name: "year",
+		Labels: func(d streams.PredictionDocument) []string {
+			if d.MadeOn == nil {
+				return nil
+			}
+			return []string{strconv.Itoa(d.MadeOn.Year())}
+		},
+		Title: func(label string) string { return "Made in " + label },
+		Order: func(_ []streams.Stream, labels []string) []string {
+			sort.Strings(labels)
+			return labels
+		},
+	},
+}
+
+// tagLabels returns a prediction’s tags and every tag they’re nested in, since a prediction tagged “politics/us” goes in the group for “politics” too.
+func tagLabels(d streams.PredictionDocument) []string {
+	ret := make([]string, 0, len(d.Tags))
+	for _, tag := range d.Tags {
+		for t := tag; t != ""; t = streams.ParentTag(t) {
+			ret = append(ret, t)
+		}
+	}
+	return ret
+}
+
+func tagTitle(label string) string {
+	return "Tag: " + label
+}
+
+// labelDateFormat is how the start of a period is written in a label.
+const labelDateFormat = "2006-01-02"
+
+// DefaultGroupings are the groupings Analyze makes when no GroupBy option says otherwise.
+var DefaultGroupings = []string{"key", "tag", "confidence", "interval-confidence", "resolved"}
+
+// ListingGroupings are the groupings that lists of predictions are broken down into when no GroupBy option says otherwise.
+var ListingGroupings = []string{"key", "tag"}
+
+// ParseGrouping turns a list of dimension names separated by commas, like “tag,year”, into the dimensions of a grouping. The names are “key” (the title and scope of the stream a prediction is in), “tag”, “confidence”, “interval-confidence”, “resolved” (the month or quarter it was resolved in), and “year” (the year it was made in).
+func ParseGrouping(s string) ([]Dimension, error) {
+	ret := make([]Dimension, 0)
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		d, ok := dimensions[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown dimension “%s”; try “key”, “tag”, “confidence”, “interval-confidence”, “resolved”, or “year”", name)
+		}
+		ret = append(ret, d)
+	}
+	return ret, nil
+}
+
+// group groups predictions by the given dimensions, which must already be configured.
+func group(sts []streams.Stream, dims []Dimension) Grouping {
+	names := make([]string, len(dims))
+	orders := make([][]string, len(dims))
+	for i, dim := range dims {
+		names[i] = dim.Name
+
+		seen := make(map[string]bool)
+		labels := make([]string, 0)
+		for _, st := range sts {
+			for _, d := range st.Predictions {
+				for _, label := range dim.Labels(d) {
+					if !seen[label] {
+						seen[label] = true
+						labels = append(labels, label)
+					}
+				}
+			}
+		}
+
+		if dim.Order != nil {
+			labels = dim.Order(sts, labels)
+		}
+		orders[i] = labels
+	}
+
+	ret := Grouping{Name: strings.Join(names, ",")}
+
+	var visit func(labels []string)
+	visit = func(labels []string) {
+		if len(labels) < len(dims) {
+			for _, label := range orders[len(labels)] {
+				visit(append(labels[:len(labels):len(labels)], label))
+			}
+			return
+		}
+
+		ads := Only(sts, func(d streams.PredictionDocument) bool {
+			for i, dim := range dims {
+				if !containsString(dim.Labels(d), labels[i]) {
+					return false
+				}
+			}
+			return true
+		})
+		if len(ads.Documents) == 0 {
+			return
+		}
+
+		titles := make([]string, len(dims))
+		for i, dim := range dims {
+			titles[i] = labels[i]
+			if dim.Title != nil {
+				titles[i] = dim.Title(labels[i])
+			}
+		}
+		ads.AnalysisUnit.Title = strings.Join(titles, " × ")
+		ads.Labels = labels
+		ret.Groups = append(ret.Groups, ads)
+	}
+	visit(nil)
+
+	return ret
+}
+
+func containsString(ss []string, s string) bool {
+	for _, t := range ss {
+		if t == s {
+			return true
+		}
+	}
+	return false
+}
+
+// formatConfidence writes a confidence as a label, with no more digits than it needs.
+func formatConfidence(c float64) string {
+	return strconv.FormatFloat(c, 'f', -1, 64)
+}
+
+// roundConfidence rounds a confidence label to a whole number, like the titles of confidence groups have always had.
+func roundConfidence(label string) string {
+	c, _ := strconv.ParseFloat(label, 64)
+	return fmt.Sprintf("%.0f", c)
+}
+
+// numericOrder puts labels that are numbers in numeric order.
+func numericOrder(_ []streams.Stream, labels []string) []string {
+	sort.SliceStable(labels, func(i, j int) bool {
+		a, _ := strconv.ParseFloat(labels[i], 64)
+		b, _ := strconv.ParseFloat(labels[j], 64)
+		return a < b
+	})
+	return labels
+}
@@ -0,0 +1,90 @@
+// © 2019 Nathan Galt
+//
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analyze
+
+import (
+	"github.com/adiabatic/predictions/streams"
+)
+
+// Option is the type used for options that change what Analyze does.
+type Option func(o *analysisOptions)
+
+// GroupResolutionsBy is an option that says how long the periods in the “resolved” dimension should be.
+func GroupResolutionsBy(p streams.Period) Option {
+	return func(o *analysisOptions) {
+		o.resolutionPeriod = p
+	}
+}
+
+// ScoreForecast is an option that says which of each prediction’s confidences to score when predictions have updates.
+func ScoreForecast(m streams.ForecastMode) Option {
+	return func(o *analysisOptions) {
+		o.forecastMode = m
+	}
+}
+
+// SortUnlistedTagsBy is an option that says what order tags go in when no stream’s “tag order” lists them.
+func SortUnlistedTagsBy(s TagSort) Option {
+	return func(o *analysisOptions) {
+		o.tagSort = s
+	}
+}
+
+// GroupBy is an option that asks for predictions to be grouped by the given dimensions. With more than one dimension, there’s a group for every combination of their labels, like tag × year. Each GroupBy adds another grouping; without any, Analyze makes the DefaultGroupings.
+func GroupBy(dims ...Dimension) Option {
+	return func(o *analysisOptions) {
+		o.groupings = append(o.groupings, dims)
+	}
+}
+
+// DefaultGroupBy is an option that says which of the built-in dimensions, like ListingGroupings, to group predictions by when no GroupBy option is given. Without it, that’s DefaultGroupings.
+func DefaultGroupBy(names ...string) Option {
+	return func(o *analysisOptions) {
+		o.defaultGroupings = names
+	}
+}
+
+type analysisOptions struct {
+	resolutionPeriod streams.Period
+	forecastMode     streams.ForecastMode
+	tagSort          TagSort
+	groupings        [][]Dimension
+	defaultGroupings []string
+}
+
+func newAnalysisOptions(options []Option) analysisOptions {
+	o := analysisOptions{
+		resolutionPeriod: streams.Month,
+		forecastMode:     streams.InitialForecast,
+		tagSort:          Alphabetical,
+		defaultGroupings: DefaultGroupings,
+	}
+	for _, f := range options {
+		f(&o)
+	}
+	return o
+}
+
+// configure returns the given dimensions as they should be, given the options.
+func (o analysisOptions) configure(dims []Dimension) []Dimension {
+	ret := make([]Dimension, len(dims))
+	for i, d := range dims {
+		ret[i] = d
+		if d.configure != nil {
+			ret[i] = d.configure(o)
+		}
+	}
+	return ret
+}
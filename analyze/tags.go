@@ -0,0 +1,141 @@
+// © 2019 Nathan Galt
+//
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analyze
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/adiabatic/predictions/streams"
+)
+
+// A TagSort says what order tags go in when no stream’s “tag order” lists them.
+type TagSort int
+
+// Tag sorts.
+const (
+	// Alphabetical puts tags in alphabetical order, ignoring case.
+	Alphabetical TagSort = iota
+
+	// ByCount puts the tags with the most predictions first.
+	ByCount
+
+	// ByBrierScore puts the tags with the best (lowest) Brier scores first. Tags with nothing scored go last.
+	ByBrierScore
+)
+
+// ParseTagSort turns “alphabetical”, “count”, or “brier” into a TagSort.
+func ParseTagSort(s string) (TagSort, error) {
+	switch strings.ToLower(s) {
+	case "alphabetical", "alpha", "name":
+		return Alphabetical, nil
+	case "count", "by-count":
+		return ByCount, nil
+	case "brier", "brier-score", "by-brier-score":
+		return ByBrierScore, nil
+	}
+	return Alphabetical, fmt.Errorf("unknown tag sort “%s”; try “alphabetical”, “count”, or “brier”", s)
+}
+
+// Tags returns every tag used in the given streams, and every tag they’re nested in, in the order they should be shown in: first the tags listed in the streams’ “tag order”, in that order, then every other tag, sorted however the options say. Tags nested in another tag come right after it, in the same kind of order.
+func Tags(sts []streams.Stream, options ...Option) []string {
+	o := newAnalysisOptions(options)
+	return o.orderedTags(streams.WithForecast(sts, o.forecastMode))
+}
+
+func (o analysisOptions) orderedTags(sts []streams.Stream) []string {
+	used := streams.TagsWithAncestors(sts)
+	isUsed := make(map[string]bool, len(used))
+	for _, tag := range used {
+		isUsed[tag] = true
+	}
+
+	ret := make([]string, 0, len(used))
+	listed := make(map[string]bool)
+	for _, tag := range streams.TagOrder(sts) {
+		listed[tag] = true
+		if isUsed[tag] {
+			ret = append(ret, tag)
+		}
+	}
+
+	unlisted := make([]string, 0, len(used))
+	for _, tag := range used {
+		if !listed[tag] {
+			unlisted = append(unlisted, tag)
+		}
+	}
+
+	var less func(a, b string) bool
+	switch o.tagSort {
+	case ByCount:
+		counts := make(map[string]int, len(unlisted))
+		for _, tag := range unlisted {
+			counts[tag] = len(streams.DocumentsMatching(sts, streams.MatchingTag(tag)))
+		}
+		less = func(a, b string) bool { return counts[a] > counts[b] }
+	case ByBrierScore:
+		scores := make(map[string]float64, len(unlisted))
+		for _, tag := range unlisted {
+			au := Only(sts, streams.MatchingTag(tag)).AnalysisUnit
+			scores[tag] = au.BrierScore()
+		}
+		less = func(a, b string) bool {
+			if math.IsNaN(scores[b]) {
+				return !math.IsNaN(scores[a])
+			}
+			return scores[a] < scores[b]
+		}
+	default:
+		less = func(a, b string) bool {
+			if la, lb := strings.ToLower(a), strings.ToLower(b); la != lb {
+				return la < lb
+			}
+			return a < b
+		}
+	}
+
+	sort.SliceStable(unlisted, func(i, j int) bool { return less(unlisted[i], unlisted[j]) })
+	return nestTags(append(ret, unlisted...))
+}
+
+// nestTags reorders the given tags so every tag nested in another one comes right after it and whatever else is nested in it. Otherwise, tags keep the order they were given in.
+func nestTags(tags []string) []string {
+	children := make(map[string][]string)
+	roots := make([]string, 0)
+	for _, tag := range tags {
+		parent := streams.ParentTag(tag)
+		if parent == "" {
+			roots = append(roots, tag)
+			continue
+		}
+		children[parent] = append(children[parent], tag)
+	}
+
+	ret := make([]string, 0, len(tags))
+	var visit func(tag string)
+	visit = func(tag string) {
+		ret = append(ret, tag)
+		for _, child := range children[tag] {
+			visit(child)
+		}
+	}
+	for _, tag := range roots {
+		visit(tag)
+	}
+	return ret
+}
@@ -0,0 +1,127 @@
+// © 2019 Nathan Galt
+//
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/adiabatic/predictions/streams"
+	"github.com/spf13/cobra"
+)
+
+var (
+	addClaim      string
+	addConfidence float64
+	addTags       []string
+	addDue        string
+	addHash       bool
+)
+
+func init() {
+	addCommand.Flags().StringVar(&addClaim, "claim", "", "what you think will happen")
+	addCommand.Flags().Float64Var(&addConfidence, "confidence", 0, "how confident you are that it will happen, from 0 to 100")
+	addCommand.Flags().StringArrayVar(&addTags, "tag", nil, "give the prediction `TAG` (may be repeated)")
+	addCommand.Flags().StringVar(&addDue, "due", "", "when you expect to know whether it happened, as `YYYY-MM-DD`")
+	addCommand.Flags().BoolVar(&addHash, "hash", false, "hash the claim when publishing")
+	rootCommand.AddCommand(addCommand)
+}
+
+var addCommand = &cobra.Command{
+	Use:   "add FILE --claim CLAIM --confidence CONFIDENCE",
+	Short: "Adds a prediction to the end of a file",
+	Long: `Adds a prediction, made today, to the end of a file. Nothing already in the file is changed.
+
+The prediction is checked before it’s written; if it has no claim or no confidence, FILE is left alone.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		fn := args[0]
+
+		d := streams.PredictionDocument{
+			Claim: addClaim,
+			Tags:  addTags,
+			Hash:  addHash,
+		}
+		if cmd.Flags().Changed("confidence") {
+			d.Confidence = &addConfidence
+		}
+
+		y, m, day := time.Now().Date()
+		today := time.Date(y, m, day, 0, 0, 0, 0, time.UTC)
+		d.MadeOn = &today
+
+		if addDue != "" {
+			due, err := time.Parse("2006-01-02", addDue)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "couldn’t read due date “%s”; write it like 2019-12-31\n", addDue)
+				os.Exit(1)
+			}
+			d.Due = &due
+		}
+
+		if err := addPredictionToFile(fn, d); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	},
+}
+
+func addPredictionToFile(fn string, d streams.PredictionDocument) error {
+	fi, err := os.Stat(fn)
+	if err != nil {
+		return err
+	}
+
+	// Problems with what’s already in the file are lint’s business, as long as the file could be read at all.
+	sts, err := streams.FromFiles([]string{fn})
+	if len(sts) == 0 {
+		return err
+	}
+	st := sts[0]
+	st.Predictions = append(st.Predictions, d)
+
+	var buf bytes.Buffer
+	if err := streams.ToWriter(&buf, st); err != nil {
+		return err
+	}
+	out := buf.Bytes()
+
+	// Read what would be written back in and check the new prediction as it’ll be read from now on.
+	st, _ = streams.FromReader(bytes.NewReader(out))
+	last := len(st.Predictions) - 1
+	if last < 0 {
+		return fmt.Errorf("%s: couldn’t read the new prediction back in; nothing was written", fn)
+	}
+	for i := range st.Predictions {
+		st.Predictions[i].Position.Filename = fn
+	}
+
+	v := streams.Validator{}
+	failed := false
+	for _, err := range v.RunMinimal(st) {
+		if p, ok := err.(*streams.Problem); ok && p.Index == last {
+			fmt.Fprintln(os.Stderr, err)
+			failed = true
+		}
+	}
+	if failed {
+		return fmt.Errorf("%s: nothing was written", fn)
+	}
+
+	return ioutil.WriteFile(fn, out, fi.Mode())
+}
@@ -15,10 +15,31 @@
 package cmd
 
 import (
+	"fmt"
+	"os"
+
+	"github.com/adiabatic/predictions/analyze"
+	"github.com/adiabatic/predictions/formatters"
+	"github.com/adiabatic/predictions/streams"
 	"github.com/spf13/cobra"
 )
 
+var (
+	analyzeFormat   string
+	analyzeForecast string
+	analyzeTagSort  string
+	analyzeWhere    string
+	analyzeGroupBy  []string
+	analyzePeriod   string
+)
+
 func init() {
+	analyzeCommand.Flags().StringVar(&analyzeFormat, "format", "markdown", "print the analysis as `FORMAT` (markdown or json)")
+	analyzeCommand.Flags().StringVar(&analyzePeriod, "resolution-period", "month", "group resolved predictions by `PERIOD` (month or quarter)")
+	analyzeCommand.Flags().StringVar(&analyzeForecast, "forecast", "initial", "score each updated prediction’s `FORECAST` (initial, final, or time-weighted) confidence")
+	analyzeCommand.Flags().StringVar(&analyzeTagSort, "tag-sort", "alphabetical", "order tags no stream’s “tag order” lists by `SORT` (alphabetical, count, or brier)")
+	analyzeCommand.Flags().StringVar(&analyzeWhere, "where", "", "only use predictions that match `QUERY`, like “tag:work and confidence>=80”")
+	analyzeCommand.Flags().StringArrayVar(&analyzeGroupBy, "group-by", nil, "group predictions by `DIMENSIONS` (key, tag, confidence, interval-confidence, resolved, or year), separated by commas for combinations like tag,year (may be repeated)")
 	rootCommand.AddCommand(analyzeCommand)
 }
 
@@ -28,5 +49,54 @@ var analyzeCommand = &cobra.Command{
 	Short:                 "Runs analyses on your predictions",
 	DisableFlagsInUseLine: true,
 	Args:                  cobra.MinimumNArgs(1),
-	Run:                   printMarkdown(false),
+	Run: func(cmd *cobra.Command, args []string) {
+		period, err := streams.ParsePeriod(analyzePeriod)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		mode, err := streams.ParseForecastMode(analyzeForecast)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		tagSort, err := analyze.ParseTagSort(analyzeTagSort)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		analysis := formatters.AnalysisOptions(append(parseGroupBy(analyzeGroupBy), analyze.GroupResolutionsBy(period), analyze.ScoreForecast(mode), analyze.SortUnlistedTagsBy(tagSort))...)
+		where := parseWhere(analyzeWhere)
+
+		switch analyzeFormat {
+		case "markdown", "md":
+			printMarkdown(false, where, analysis)(cmd, args)
+		case "json":
+			printJSON(cmd, args, where, analysis)
+		default:
+			fmt.Fprintf(os.Stderr, "unknown format “%s”; try “markdown” or “json”\n", analyzeFormat)
+			os.Exit(1)
+		}
+	},
+}
+
+func printJSON(cmd *cobra.Command, args []string, where streams.Filter, options ...formatters.Option) {
+	sts := readStreams(args)
+
+	v := streams.Validator{}
+
+	for _, st := range sts {
+		errs := v.RunAll(st)
+		for _, err := range errs {
+			cmd.Println(err)
+		}
+	}
+
+	sts = streams.Where(sts, where)
+
+	err := formatters.JSONFromStreams(os.Stdout, sts, options...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
 }
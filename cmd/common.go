@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/adiabatic/predictions/analyze"
 	"github.com/adiabatic/predictions/formatters"
 	"github.com/adiabatic/predictions/streams"
 	"github.com/spf13/cobra"
@@ -25,13 +26,50 @@ import (
 
 type runFunction func(*cobra.Command, []string)
 
-func printMarkdown(forPublic bool) runFunction {
-	return func(cmd *cobra.Command, args []string) {
-		sts, err := streams.FromFiles(args)
+// readStreams reads streams from files, printing everything that couldn’t be read. It only exits if nothing at all could be read.
+func readStreams(filenames []string) []streams.Stream {
+	sts, err := streams.FromFiles(filenames)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		if len(sts) == 0 {
+			os.Exit(1)
+		}
+		fmt.Fprintln(os.Stderr, "warning: carrying on with everything that could be read")
+	}
+	return sts
+}
+
+// parseWhere compiles a --where query into a Filter, exiting if it can’t be compiled. An empty query matches everything.
+func parseWhere(query string) streams.Filter {
+	if query == "" {
+		return streams.Everything
+	}
+
+	f, err := streams.ParseFilter(query)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "couldn’t understand --where: %s\n", err)
+		os.Exit(1)
+	}
+	return f
+}
+
+// parseGroupBy turns --group-by flags into analysis options, exiting if any of them can’t be understood. No flags means no options, so the default groupings are used.
+func parseGroupBy(groupings []string) []analyze.Option {
+	ret := make([]analyze.Option, 0, len(groupings))
+	for _, s := range groupings {
+		dims, err := analyze.ParseGrouping(s)
 		if err != nil {
-			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintf(os.Stderr, "couldn’t understand --group-by: %s\n", err)
 			os.Exit(1)
 		}
+		ret = append(ret, analyze.GroupBy(dims...))
+	}
+	return ret
+}
+
+func printMarkdown(forPublic bool, where streams.Filter, options ...formatters.Option) runFunction {
+	return func(cmd *cobra.Command, args []string) {
+		sts := readStreams(args)
 
 		v := streams.Validator{}
 
@@ -42,7 +80,12 @@ func printMarkdown(forPublic bool) runFunction {
 			}
 		}
 
-		fmt.Print(formatters.MarkdownFromStreams(sts, formatters.ForPublic(forPublic)))
+		sts = streams.Where(sts, where)
 
+		fmt.Print(formatters.MarkdownFromStreams(sts, append(options, formatters.ForPublic(forPublic))...))
+
+		if !forPublic {
+			fmt.Print(formatters.MarkdownStatisticsFromStreams(sts, options...))
+		}
 	}
 }
@@ -0,0 +1,86 @@
+// © 2019 Nathan Galt
+//
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/adiabatic/predictions/streams"
+	"github.com/spf13/cobra"
+)
+
+var fmtCheck bool
+
+func init() {
+	fmtCommand.Flags().BoolVar(&fmtCheck, "check", false, "don’t change anything; list files that aren’t formatted and fail if there are any")
+	rootCommand.AddCommand(fmtCommand)
+}
+
+var fmtCommand = &cobra.Command{
+	Use:   "fmt FILE …",
+	Short: "Rewrites files of predictions in the canonical style",
+	Long: `Rewrites files of predictions in the canonical style, in place: keys in a fixed order, “true” and “false” instead of “yes” and “no”, tags in brackets, and long claims wrapped. Comments are kept.
+
+With --check, nothing is changed. Instead, the name of every file that isn’t formatted is printed, and fmt exits with status 1 if there are any.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		failed := false
+		for _, fn := range args {
+			changed, err := formatFile(fn, !fmtCheck)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				failed = true
+				continue
+			}
+			if changed && fmtCheck {
+				fmt.Println(fn)
+				failed = true
+			}
+		}
+
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+// formatFile formats a file, writing it back if write is true. It returns true if the file wasn’t already formatted.
+func formatFile(fn string, write bool) (bool, error) {
+	fi, err := os.Stat(fn)
+	if err != nil {
+		return false, err
+	}
+
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return false, err
+	}
+
+	out, err := streams.Format(b)
+	if err != nil {
+		return false, fmt.Errorf("%s: %s", fn, err)
+	}
+	if bytes.Equal(b, out) {
+		return false, nil
+	}
+
+	if !write {
+		return true, nil
+	}
+	return true, ioutil.WriteFile(fn, out, fi.Mode())
+}
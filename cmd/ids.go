@@ -0,0 +1,83 @@
+// © 2019 Nathan Galt
+//
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+
+	"github.com/adiabatic/predictions/streams"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCommand.AddCommand(idsCommand)
+}
+
+var idsCommand = &cobra.Command{
+	Use:   "ids FILE …",
+	Short: "Adds an ID to every prediction that doesn’t have one",
+	Long: `Adds an “id” line to every prediction that doesn’t have one, editing files in place.
+
+New IDs are random, so they don’t give away anything about hashed claims, and they don’t clash with IDs already used in any of the files given. Everything else in the files, comments included, is left as it was.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		sts := readStreams(args)
+		used := streams.IDsUsed(sts)
+		newID := func() string {
+			for {
+				id := randomID()
+				if _, ok := used[id]; !ok {
+					used[id] = struct{}{}
+					return id
+				}
+			}
+		}
+
+		failed := false
+		for _, st := range sts {
+			n := streams.AddMissingIDs(&st, newID)
+			if n == 0 {
+				continue
+			}
+
+			if err := streams.ToFile(st.FromFilename, st); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				failed = true
+				continue
+			}
+			if n == 1 {
+				fmt.Printf("%s: added 1 ID\n", st.FromFilename)
+			} else {
+				fmt.Printf("%s: added %d IDs\n", st.FromFilename, n)
+			}
+		}
+
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+// randomID returns eight random hexadecimal digits.
+func randomID() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		panic("could not read random bytes: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
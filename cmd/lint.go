@@ -0,0 +1,92 @@
+// © 2019 Nathan Galt
+//
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/adiabatic/predictions/formatters"
+	"github.com/adiabatic/predictions/streams"
+	"github.com/spf13/cobra"
+)
+
+var (
+	lintFormat      string
+	lintMaxWarnings int
+)
+
+func init() {
+	lintCommand.Flags().StringVar(&lintFormat, "format", "text", "print diagnostics as `FORMAT` (text, json, or sarif)")
+	lintCommand.Flags().IntVar(&lintMaxWarnings, "max-warnings", -1, "exit with an error if there are more than `N` warnings (-1 means no limit)")
+	rootCommand.AddCommand(lintCommand)
+}
+
+var lintCommand = &cobra.Command{
+	Use:   "lint FILE …",
+	Short: "Checks your predictions for errors and warnings",
+	Long: `Checks your predictions for errors and warnings.
+
+Exits with status 1 if there are any errors or more warnings than --max-warnings allows, and 0 otherwise.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var write func(io.Writer, []formatters.Diagnostic) error
+		switch lintFormat {
+		case "text":
+			write = formatters.TextFromDiagnostics
+		case "json":
+			write = formatters.JSONFromDiagnostics
+		case "sarif":
+			write = formatters.SARIFFromDiagnostics
+		default:
+			fmt.Fprintf(os.Stderr, "unknown format “%s”; try “text”, “json”, or “sarif”\n", lintFormat)
+			os.Exit(2)
+		}
+
+		ds := lint(args)
+
+		if err := write(os.Stdout, ds); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+
+		errors, warnings := formatters.CountDiagnostics(ds)
+		if errors > 0 {
+			os.Exit(1)
+		}
+		if lintMaxWarnings >= 0 && warnings > lintMaxWarnings {
+			fmt.Fprintf(os.Stderr, "too many warnings (%d); no more than %d allowed\n", warnings, lintMaxWarnings)
+			os.Exit(1)
+		}
+	},
+}
+
+// lint reads and validates every file, carrying on past anything that can’t be read.
+func lint(filenames []string) []formatters.Diagnostic {
+	sts, err := streams.FromFiles(filenames)
+
+	errs := make([]error, 0)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	v := streams.Validator{}
+	for _, st := range sts {
+		errs = append(errs, v.RunAll(st)...)
+	}
+
+	return formatters.DiagnosticsFromErrors(errs)
+}
@@ -0,0 +1,84 @@
+// © 2019 Nathan Galt
+//
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/adiabatic/predictions/streams"
+	"github.com/spf13/cobra"
+)
+
+var (
+	newTitle string
+	newScope string
+	newTags  []string
+)
+
+func init() {
+	newCommand.Flags().StringVar(&newTitle, "title", "", "the stream’s `TITLE` (default “Predictions for” and this year)")
+	newCommand.Flags().StringVar(&newScope, "scope", "", "the stream’s `SCOPE` (default “in” and this year)")
+	newCommand.Flags().StringArrayVar(&newTags, "tag", nil, "give the example prediction `TAG` (may be repeated)")
+	rootCommand.AddCommand(newCommand)
+}
+
+var newCommand = &cobra.Command{
+	Use:   "new FILE",
+	Short: "Makes a new file of predictions",
+	Long: `Makes a new file of predictions with a title, a scope, a random salt, and an example prediction to get you started.
+
+FILE must not already exist.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		now := time.Now()
+		title, scope := newTitle, newScope
+		if title == "" {
+			title = fmt.Sprintf("Predictions for %d", now.Year())
+		}
+		if scope == "" {
+			scope = fmt.Sprintf("in %d", now.Year())
+		}
+
+		b := streams.NewStreamFile(title, scope, randomSalt(), newTags, now)
+
+		f, err := os.OpenFile(args[0], os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if _, err := f.Write(b); err != nil {
+			f.Close()
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if err := f.Close(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	},
+}
+
+// randomSalt returns 32 random hexadecimal digits.
+func randomSalt() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic("could not read random bytes: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
@@ -25,7 +25,20 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var (
+	resolutionPeriod string
+	htmlForecast     string
+	htmlTagSort      string
+	htmlWhere        string
+	htmlGroupBy      []string
+)
+
 func init() {
+	publishHTMLCommand.Flags().StringVar(&resolutionPeriod, "resolution-period", "month", "group resolved predictions by `PERIOD` (month or quarter)")
+	publishHTMLCommand.Flags().StringVar(&htmlForecast, "forecast", "initial", "score each updated prediction’s `FORECAST` (initial, final, or time-weighted) confidence")
+	publishHTMLCommand.Flags().StringVar(&htmlTagSort, "tag-sort", "alphabetical", "order tags no stream’s “tag order” lists by `SORT` (alphabetical, count, or brier)")
+	publishHTMLCommand.Flags().StringVar(&htmlWhere, "where", "", "only use predictions that match `QUERY`, like “tag:work and confidence>=80”")
+	publishHTMLCommand.Flags().StringArrayVar(&htmlGroupBy, "group-by", nil, "group predictions by `DIMENSIONS` (key, tag, confidence, interval-confidence, resolved, or year), separated by commas for combinations like tag,year (may be repeated)")
 	publishCommand.AddCommand(publishHTMLCommand)
 }
 
@@ -43,12 +56,29 @@ var publishHTMLCommand = &cobra.Command{
 	Short:                 "Formats your predictions as an HTML file",
 	DisableFlagsInUseLine: true,
 	Run: func(cmd *cobra.Command, args []string) {
-		sts, err := streams.FromFiles(args)
+		period, err := streams.ParsePeriod(resolutionPeriod)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		mode, err := streams.ParseForecastMode(htmlForecast)
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			os.Exit(1)
 		}
 
+		tagSort, err := analyze.ParseTagSort(htmlTagSort)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		where := parseWhere(htmlWhere)
+		groupBy := parseGroupBy(htmlGroupBy)
+
+		sts := readStreams(args)
+
 		v := streams.Validator{}
 
 		for _, st := range sts {
@@ -58,7 +88,12 @@ var publishHTMLCommand = &cobra.Command{
 			}
 		}
 
-		err = formatters.HTMLFromStreams(os.Stdout, sts)
+		sts = streams.Where(sts, where)
+
+		err = formatters.HTMLFromStreams(os.Stdout, sts,
+			formatters.ForPublic(true),
+			formatters.AnalysisOptions(append(groupBy, analyze.GroupResolutionsBy(period), analyze.ScoreForecast(mode), analyze.SortUnlistedTagsBy(tagSort))...),
+		)
 		if err != nil {
 			cmd.Println("error when executing template: ", err)
 			os.Exit(2)
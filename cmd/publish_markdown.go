@@ -15,10 +15,27 @@
 package cmd
 
 import (
+	"fmt"
+	"os"
+
+	"github.com/adiabatic/predictions/analyze"
+	"github.com/adiabatic/predictions/formatters"
+	"github.com/adiabatic/predictions/streams"
 	"github.com/spf13/cobra"
 )
 
+var (
+	markdownTagSort string
+	markdownWhere   string
+	markdownGroupBy []string
+	markdownPeriod  string
+)
+
 func init() {
+	publishMarkdownCommand.Flags().StringVar(&markdownPeriod, "resolution-period", "month", "group resolved predictions by `PERIOD` (month or quarter)")
+	publishMarkdownCommand.Flags().StringVar(&markdownTagSort, "tag-sort", "alphabetical", "order tags no stream’s “tag order” lists by `SORT` (alphabetical, count, or brier)")
+	publishMarkdownCommand.Flags().StringVar(&markdownWhere, "where", "", "only use predictions that match `QUERY`, like “tag:work and confidence>=80”")
+	publishMarkdownCommand.Flags().StringArrayVar(&markdownGroupBy, "group-by", nil, "group predictions by `DIMENSIONS` (key, tag, confidence, interval-confidence, resolved, or year), separated by commas for combinations like tag,year (may be repeated)")
 	publishCommand.AddCommand(publishMarkdownCommand)
 }
 
@@ -28,5 +45,19 @@ var publishMarkdownCommand = &cobra.Command{
 	Args:                  cobra.MinimumNArgs(1),
 	Short:                 "Prints your predictions as Markdown lists with headers",
 	DisableFlagsInUseLine: true,
-	Run:                   printMarkdown(true),
+	Run: func(cmd *cobra.Command, args []string) {
+		period, err := streams.ParsePeriod(markdownPeriod)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		tagSort, err := analyze.ParseTagSort(markdownTagSort)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		printMarkdown(true, parseWhere(markdownWhere), formatters.AnalysisOptions(append(parseGroupBy(markdownGroupBy), analyze.GroupResolutionsBy(period), analyze.SortUnlistedTagsBy(tagSort))...))(cmd, args)
+	},
 }
@@ -0,0 +1,207 @@
+// © 2019 Nathan Galt
+//
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adiabatic/predictions/streams"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCommand.AddCommand(resolveCommand)
+}
+
+var resolveCommand = &cobra.Command{
+	Use:   "resolve FILE …",
+	Short: "Asks how each ongoing prediction turned out and writes the answers down",
+	Long: `Goes through every ongoing prediction — one with no “happened” value and no cause for exclusion — and asks how it turned out. Answers are written back into the files as you go, with today’s date as “resolved on”. Everything else in the files, comments included, is left as it was.
+
+Predictions you skip stay ongoing. Quitting keeps every answer given so far.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		sts := readStreams(args)
+
+		y, m, d := time.Now().Date()
+		today := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+		q := questioner{in: bufio.NewReader(os.Stdin)}
+
+		failed := false
+		for _, st := range sts {
+			quit, err := resolveStream(st, q, today)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				failed = true
+			}
+			if quit {
+				break
+			}
+		}
+
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+// errQuit is returned by a questioner when there are no more answers to be had.
+var errQuit = errors.New("quit")
+
+type questioner struct {
+	in *bufio.Reader
+}
+
+// ask prints a prompt and returns the answer, trimmed. It returns errQuit when the answer is “q” or there’s nothing left to read.
+func (q questioner) ask(prompt string) (string, error) {
+	fmt.Print(prompt)
+	line, err := q.in.ReadString('\n')
+	if err == io.EOF && line == "" {
+		fmt.Println()
+		return "", errQuit
+	}
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+
+	answer := strings.TrimSpace(line)
+	if answer == "q" {
+		return "", errQuit
+	}
+	return answer, nil
+}
+
+// resolveStream asks about every ongoing prediction in a stream and writes each answer to its file as soon as it’s given. It returns true if there shouldn’t be any more questions.
+func resolveStream(st streams.Stream, q questioner, today time.Time) (bool, error) {
+	quit := false
+	for i, d := range st.Predictions {
+		if !d.IsOngoing() {
+			continue
+		}
+
+		r, ok, err := askResolution(d, q)
+		if err == errQuit {
+			quit = true
+		} else if err != nil {
+			return true, err
+		}
+
+		if ok {
+			if r.CauseForExclusion == "" && d.ResolvedOn == nil {
+				r.ResolvedOn = &today
+			}
+			st.Predictions[i].Resolve(r)
+			if err := streams.ToFile(st.FromFilename, st); err != nil {
+				return true, err
+			}
+		}
+
+		if quit {
+			break
+		}
+	}
+
+	return quit, nil
+}
+
+// askResolution asks how a prediction turned out. It returns false if the prediction was skipped.
+func askResolution(d streams.PredictionDocument, q questioner) (streams.Resolution, bool, error) {
+	var r streams.Resolution
+
+	fmt.Printf("\n%s\n%s\n", d.Position, d.Claim)
+	switch {
+	case d.IsCategorical():
+		for i, o := range d.Outcomes {
+			fmt.Printf("  %d. %s (%v%%)\n", i+1, o.Name, o.Confidence)
+		}
+	case d.IsInterval():
+		fmt.Printf("between %v and %v\n", orQuestionMark(d.Low), orQuestionMark(d.High))
+	}
+	if !d.IsCategorical() && d.Confidence != nil {
+		fmt.Printf("%v%% confident\n", *d.Confidence)
+	}
+	if d.Due != nil {
+		fmt.Printf("due %s\n", d.Due.Format("2006-01-02"))
+	}
+
+	for {
+		var prompt string
+		switch {
+		case d.IsCategorical():
+			prompt = "Which happened? Give its number, or x to exclude, s to skip, or q to quit: "
+		case d.IsInterval():
+			prompt = "What was the actual value? Give a number, or x to exclude, s to skip, or q to quit: "
+		default:
+			prompt = "Did it happen? y for yes, n for no, x to exclude, s to skip, or q to quit: "
+		}
+
+		answer, err := q.ask(prompt)
+		if err != nil {
+			return r, false, err
+		}
+
+		switch {
+		case answer == "s":
+			return r, false, nil
+		case answer == "x":
+			for r.CauseForExclusion == "" {
+				if r.CauseForExclusion, err = q.ask("Why should it be excluded? "); err != nil {
+					return r, false, err
+				}
+			}
+		case d.IsCategorical():
+			i, err := strconv.Atoi(answer)
+			if err != nil || i < 1 || i > len(d.Outcomes) {
+				continue
+			}
+			r.HappenedOutcome = d.Outcomes[i-1].Name
+		case d.IsInterval():
+			f, err := strconv.ParseFloat(answer, 64)
+			if err != nil {
+				continue
+			}
+			r.Actual = &f
+		case answer == "y" || answer == "n":
+			happened := answer == "y"
+			r.Happened = &happened
+		default:
+			continue
+		}
+		break
+	}
+
+	// Quitting here still keeps the answer above.
+	notes, err := q.ask("Notes (optional): ")
+	if err != nil && err != errQuit {
+		return r, false, err
+	}
+	r.Notes = notes
+
+	return r, true, err
+}
+
+func orQuestionMark(f *float64) string {
+	if f == nil {
+		return "?"
+	}
+	return strconv.FormatFloat(*f, 'f', -1, 64)
+}
@@ -0,0 +1,74 @@
+// © 2019 Nathan Galt
+//
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var revealHashes []string
+
+func init() {
+	revealCommand.Flags().StringSliceVar(&revealHashes, "hash", nil, "only reveal predictions whose hashes start with `HASH` (may be repeated)")
+	rootCommand.AddCommand(revealCommand)
+}
+
+var revealCommand = &cobra.Command{
+	Use:   "reveal FILE …",
+	Short: "Prints hashed predictions’ claims, salts, and hashes so others can verify them",
+	Long: `Prints the claim, salt, and hash of every prediction that’s hashed when published.
+
+Anyone who knows a claim and its salt can check its hash themselves with:
+
+	printf "%s" "CLAIMSALT" | shasum -a 256`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		sts := readStreams(args)
+
+		first := true
+		for _, st := range sts {
+			for _, d := range st.Predictions {
+				if !d.ShouldHash() || !matchesAnyHashPrefix(d.HashedClaim(), revealHashes) {
+					continue
+				}
+
+				if !first {
+					fmt.Println()
+				}
+				first = false
+
+				fmt.Printf("claim: %s\n", d.ClaimToHash())
+				fmt.Printf("salt:  %s\n", d.EffectiveSalt())
+				fmt.Printf("hash:  %s\n", d.HashedClaim())
+			}
+		}
+	},
+}
+
+func matchesAnyHashPrefix(hash string, prefixes []string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(hash, strings.ToLower(prefix)) {
+			return true
+		}
+	}
+	return false
+}
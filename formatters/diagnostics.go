@@ -0,0 +1,237 @@
+// © 2019 Nathan Galt
+//
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package formatters
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/adiabatic/predictions/streams"
+)
+
+// UnreadableID is the identifier given to errors that come from not being able to read a stream at all, like a file that doesn’t exist or isn’t YAML.
+const UnreadableID = "error.stream.unreadable"
+
+// A Diagnostic is an error or warning found when linting streams, ready to be reported.
+type Diagnostic struct {
+	ID       string `json:"id"`
+	Severity string `json:"severity"`
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Column   int    `json:"column,omitempty"`
+	Message  string `json:"message"`
+
+	text string // the error as it’d be printed anywhere else
+}
+
+// DiagnosticsFromErrors turns errors, whether from reading streams or from validating them, into Diagnostics.
+//
+// Each error in a streams.DecodeErrors gets its own Diagnostic. Errors that aren’t streams.Problems get UnreadableID as their identifier.
+func DiagnosticsFromErrors(errs []error) []Diagnostic {
+	ret := make([]Diagnostic, 0, len(errs))
+	for _, err := range errs {
+		if des, ok := err.(streams.DecodeErrors); ok {
+			ret = append(ret, DiagnosticsFromErrors(des)...)
+			continue
+		}
+
+		d := Diagnostic{
+			Severity: streams.SeverityOf(err).String(),
+			text:     err.Error(),
+		}
+
+		if p, ok := err.(*streams.Problem); ok {
+			d.ID = p.ID
+			d.File = p.Position.Filename
+			d.Line = p.Position.Line
+			d.Column = p.Position.Column
+			d.Message = p.Message
+		} else {
+			d.ID = UnreadableID
+			d.Message = err.Error()
+		}
+
+		ret = append(ret, d)
+	}
+	return ret
+}
+
+// CountDiagnostics returns how many of the given diagnostics are errors and how many are warnings.
+func CountDiagnostics(ds []Diagnostic) (errors, warnings int) {
+	for _, d := range ds {
+		if d.Severity == streams.Warning.String() {
+			warnings++
+		} else {
+			errors++
+		}
+	}
+	return errors, warnings
+}
+
+// TextFromDiagnostics writes diagnostics to w, errors first, then warnings, then a count of each.
+func TextFromDiagnostics(w io.Writer, ds []Diagnostic) error {
+	for _, severity := range []streams.Severity{streams.Error, streams.Warning} {
+		for _, d := range ds {
+			if d.Severity != severity.String() {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "%s: %s\n", d.Severity, d.text); err != nil {
+				return err
+			}
+		}
+	}
+
+	errors, warnings := CountDiagnostics(ds)
+	_, err := fmt.Fprintf(w, "%s, %s\n", pluralize(errors, "error"), pluralize(warnings, "warning"))
+	return err
+}
+
+func pluralize(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// DiagnosticsSchemaVersion is the version of the schema that JSONFromDiagnostics’ output follows. It’s separate from JSONSchemaVersion, since each schema changes on its own.
+const DiagnosticsSchemaVersion = 1
+
+type jsonDiagnostics struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	Errors        int          `json:"errors"`
+	Warnings      int          `json:"warnings"`
+	Diagnostics   []Diagnostic `json:"diagnostics"`
+}
+
+// JSONFromDiagnostics writes diagnostics to w as JSON.
+func JSONFromDiagnostics(w io.Writer, ds []Diagnostic) error {
+	errors, warnings := CountDiagnostics(ds)
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(jsonDiagnostics{
+		SchemaVersion: DiagnosticsSchemaVersion,
+		Errors:        errors,
+		Warnings:      warnings,
+		Diagnostics:   ds,
+	})
+}
+
+// The subset of SARIF 2.1.0 that we need. See https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html.
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID      string `json:"id"`
+	HelpURI string `json:"helpUri"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+const (
+	informationURI = "https://github.com/adiabatic/predictions"
+	errorsHelpURI  = informationURI + "/blob/master/doc/ERRORS.md"
+)
+
+// SARIFFromDiagnostics writes diagnostics to w as a SARIF 2.1.0 log, which many editors and code-scanning tools understand.
+func SARIFFromDiagnostics(w io.Writer, ds []Diagnostic) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "predictions",
+			InformationURI: informationURI,
+			Rules:          make([]sarifRule, 0),
+		}},
+		Results: make([]sarifResult, 0, len(ds)),
+	}
+
+	seen := make(map[string]struct{})
+	for _, d := range ds {
+		if _, ok := seen[d.ID]; !ok {
+			seen[d.ID] = struct{}{}
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{ID: d.ID, HelpURI: errorsHelpURI})
+		}
+
+		r := sarifResult{
+			RuleID:  d.ID,
+			Level:   d.Severity,
+			Message: sarifMessage{Text: d.Message},
+		}
+		if d.File != "" {
+			pl := sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: d.File},
+			}
+			if d.Line > 0 {
+				pl.Region = &sarifRegion{StartLine: d.Line, StartColumn: d.Column}
+			}
+			r.Locations = []sarifLocation{{PhysicalLocation: pl}}
+		}
+		run.Results = append(run.Results, r)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	})
+}
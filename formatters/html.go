@@ -19,6 +19,7 @@ import (
 	"html/template"
 	"io"
 	"io/ioutil"
+	"math"
 	"os"
 	"strings"
 
@@ -28,6 +29,9 @@ import (
 	"github.com/russross/blackfriday/v2"
 )
 
+// dateFormat is how dates are shown in formatted output. It’s the same format YAML uses for dates.
+const dateFormat = "2006-01-02"
+
 type payload struct {
 	Title     string
 	Scope     string
@@ -35,9 +39,11 @@ type payload struct {
 	Streams   []streams.Stream
 	Analysis  analyze.Analysis
 
-	ChartJS     template.JS
-	PerfectData []Point
-	GuessData   []Point
+	ChartJS        template.JS
+	PerfectData    []Point
+	GuessData      []Point
+	GuessIntervals []Interval
+	GuessRadii     []float64
 }
 
 // A Point struct contains an x and y point. Used for Chart.js.
@@ -54,6 +60,57 @@ func (p Point) MarshalJSON() ([]byte, error) {
 	return []byte(s), nil
 }
 
+// An Interval is a vertical error bar at a given x coordinate. Used for Chart.js.
+type Interval struct {
+	X    float64 `json:"x"`
+	Low  float64 `json:"low"`
+	High float64 `json:"high"`
+}
+
+// MarshalJSON suppresses excess precision in float64’s default marshaling behavior, just like Point’s does.
+func (i Interval) MarshalJSON() ([]byte, error) {
+	s := fmt.Sprintf(`{"x":%.2f, "low":%.4f, "high":%.4f}`, i.X, i.Low, i.High)
+	return []byte(s), nil
+}
+
+// A tagNode is a tag’s group of predictions, along with the groups of every tag nested in it. Used for the collapsible tree of tags.
+type tagNode struct {
+	Title    string
+	Group    analyze.AnalyzedDocuments // untitled, since the node has the title
+	Children []tagNode
+	Nested   bool
+}
+
+// tagTree turns the groups of an Analysis’s “tag” grouping into trees of tags, one for each tag that isn’t nested in another.
+func tagTree(adss []analyze.AnalyzedDocuments) []tagNode {
+	children := make(map[string][]analyze.AnalyzedDocuments)
+	roots := make([]analyze.AnalyzedDocuments, 0)
+	for _, ads := range adss {
+		parent := streams.ParentTag(ads.Labels[0])
+		if parent == "" {
+			roots = append(roots, ads)
+			continue
+		}
+		children[parent] = append(children[parent], ads)
+	}
+
+	var node func(ads analyze.AnalyzedDocuments, nested bool) tagNode
+	node = func(ads analyze.AnalyzedDocuments, nested bool) tagNode {
+		ret := tagNode{Title: ads.AnalysisUnit.Title, Group: ads, Nested: nested}
+		ret.Group.AnalysisUnit.Title = ""
+		for _, child := range children[ads.Labels[0]] {
+			ret.Children = append(ret.Children, node(child, true))
+		}
+		return ret
+	}
+
+	ret := make([]tagNode, 0, len(roots))
+	for _, ads := range roots {
+		ret = append(ret, node(ads, false))
+	}
+	return ret
+}
+
 func documentResult(d streams.PredictionDocument) (class, message string) {
 	switch Evaluate(d) {
 	case ExcludedForCause:
@@ -70,12 +127,23 @@ func documentResult(d streams.PredictionDocument) (class, message string) {
 		return "false-positive", "missed it"
 	case MissedFalseNegative:
 		return "false-negative", "missed it"
+	case CalledOutcome:
+		return "called", "called it"
+	case MissedOutcome:
+		return "missed", "missed it"
+	case CalledInterval:
+		return "called", "called it"
+	case MissedInterval:
+		return "missed", "missed it"
 	}
 	return "logic-error", "logic error"
 }
 
 // HTMLFromStreams generates HTML output of streams and writes it to w.
-func HTMLFromStreams(w io.Writer, sts []streams.Stream) error {
+func HTMLFromStreams(w io.Writer, sts []streams.Stream, options ...Option) error {
+	o := newFormattingOptions(options)
+
+	sts = o.withoutPrivateTags(sts)
 	markdownifyNotes(sts)
 
 	var p payload
@@ -100,13 +168,35 @@ func HTMLFromStreams(w io.Writer, sts []streams.Stream) error {
 
 	p.Streams = sts
 
+	// Predictions show up in several groups, but an anchor can only be used once per page.
+	anchored := make(map[string]struct{})
+
 	funcs := template.FuncMap{
+		"anchor": func(d streams.PredictionDocument) string {
+			if !o.hasAnchor(d) {
+				return ""
+			}
+			if _, ok := anchored[d.ID]; ok {
+				return ""
+			}
+			anchored[d.ID] = struct{}{}
+			return anchorFor(d.ID)
+		},
+		"givenAnchor": func(d streams.PredictionDocument) string {
+			if d.Given == nil || d.Given.Target == nil || !o.hasAnchor(*d.Given.Target) {
+				return ""
+			}
+			return anchorFor(d.Given.ID)
+		},
 		"explainResult": func(d streams.PredictionDocument) string {
 
 			because := ""
 			switch Evaluate(d) {
 			case ExcludedForCause:
 				because = "this prediction was deliberately excluded from consideration"
+				if d.ConditionFailed {
+					because = "the prediction this one depends on didn’t turn out the way it needed to"
+				}
 			case Ongoing:
 				because = "it’s too soon to say whether this has happened or not"
 			case Resolved:
@@ -119,6 +209,14 @@ func HTMLFromStreams(w io.Writer, sts []streams.Stream) error {
 				because = "you said this would happen, but it didn’t"
 			case MissedFalseNegative:
 				because = "you said this wouldn’t happen, but it did anyway"
+			case CalledOutcome:
+				because = "nothing you thought was more likely happened instead"
+			case MissedOutcome:
+				because = "something you thought was less likely than another option happened instead"
+			case CalledInterval:
+				because = "what actually happened was within the range you gave"
+			case MissedInterval:
+				because = "what actually happened was outside the range you gave"
 			}
 
 			_, message := documentResult(d)
@@ -126,6 +224,46 @@ func HTMLFromStreams(w io.Writer, sts []streams.Stream) error {
 
 			return ret
 		},
+		"claim":             o.claim,
+		"shown":             o.shows,
+		"notes":             o.notes,
+		"causeForExclusion": o.causeForExclusion,
+		"privateNote": func(ds []streams.PredictionDocument) string {
+			hidden := 0
+			for _, d := range ds {
+				if !o.shows(d) {
+					hidden++
+				}
+			}
+
+			switch hidden {
+			case 0:
+				return ""
+			case 1:
+				return "1 private prediction isn’t shown, but it’s counted in the analysis below."
+			}
+			return fmt.Sprintf("%d private predictions aren’t shown, but they’re counted in the analysis below.", hidden)
+		},
+		"percent": func(d streams.PredictionDocument) string {
+			switch {
+			case d.IsCategorical():
+				return fmt.Sprintf("%v%%", d.Outcomes.Favorite().Confidence)
+			case d.Confidence != nil:
+				return fmt.Sprintf("%v%%", *d.Confidence)
+			}
+			return ""
+		},
+		"outcomes": func(d streams.PredictionDocument) template.HTML {
+			ss := make([]string, 0, len(d.Outcomes))
+			for i, oc := range d.Outcomes {
+				s := template.HTMLEscapeString(fmt.Sprintf("%v %v%%", o.outcomeName(d, i), oc.Confidence))
+				if oc.Name == d.HappenedOutcome {
+					s = "<b>" + s + "</b>"
+				}
+				ss = append(ss, s)
+			}
+			return template.HTML(strings.Join(ss, ", "))
+		},
 		"resultClass": func(d streams.PredictionDocument) string {
 			class, _ := documentResult(d)
 			return class
@@ -134,6 +272,55 @@ func HTMLFromStreams(w io.Writer, sts []streams.Stream) error {
 			_, message := documentResult(d)
 			return message
 		},
+		"given": func(d streams.PredictionDocument) string {
+			return o.given(d)
+		},
+		"sparkline": sparkline,
+		"tagTree":   tagTree,
+		"history": func(d streams.PredictionDocument) string {
+			history := d.ConfidenceHistory()
+			if len(history) == 0 {
+				return ""
+			}
+
+			ss := []string{fmt.Sprintf("%v%% when made", history[0].Confidence)}
+			for _, u := range d.Updates {
+				if u.Confidence == nil {
+					continue
+				}
+				s := fmt.Sprintf("%v%%", *u.Confidence)
+				if u.On != nil {
+					s += " on " + u.On.Format(dateFormat)
+				}
+				if u.Note != "" && !o.forPublic {
+					s += " (" + u.Note + ")"
+				}
+				ss = append(ss, s)
+			}
+			return strings.Join(ss, "; ")
+		},
+		"interval": func(d streams.PredictionDocument) string {
+			if d.Actual != nil {
+				return fmt.Sprintf("%s; actually %v", intervalRange(d), *d.Actual)
+			}
+			return intervalRange(d)
+		},
+		"dates": func(d streams.PredictionDocument) string {
+			ss := make([]string, 0, 3)
+			if d.MadeOn != nil {
+				ss = append(ss, "made "+d.MadeOn.Format(dateFormat))
+			}
+			if d.Due != nil {
+				ss = append(ss, "due "+d.Due.Format(dateFormat))
+			}
+			if d.ResolvedOn != nil {
+				ss = append(ss, "resolved "+d.ResolvedOn.Format(dateFormat))
+			}
+			return strings.Join(ss, "; ")
+		},
+		"percentage": func(f float64) float64 {
+			return 100 * f
+		},
 		"commaSeparate": func(ss []string) string {
 			return strings.Join(ss, ", ")
 		},
@@ -145,7 +332,7 @@ func HTMLFromStreams(w io.Writer, sts []streams.Stream) error {
 		},
 	}
 
-	p.Analysis = analyze.Analyze(sts)
+	p.Analysis = analyze.Analyze(sts, o.analysisOptions...)
 
 	addPerfectData(&p)
 	addGuessData(&p)
@@ -170,6 +357,41 @@ func HTMLFromStreams(w io.Writer, sts []streams.Stream) error {
 	return t.Execute(w, p)
 }
 
+// sparkline draws a small SVG step chart of a prediction’s confidence over time, from 0% at the bottom to 100% at the top. Each confidence gets the same width, whenever it was set.
+func sparkline(d streams.PredictionDocument) template.HTML {
+	const width, height = 80.0, 20.0
+
+	history := d.ConfidenceHistory()
+	if len(history) == 0 {
+		return ""
+	}
+
+	step := width / float64(len(history))
+	points := make([]string, 0, 2*len(history))
+	for i, p := range history {
+		y := height - p.Confidence/100*height
+		points = append(points,
+			fmt.Sprintf("%.1f,%.1f", float64(i)*step, y),
+			fmt.Sprintf("%.1f,%.1f", float64(i+1)*step, y),
+		)
+	}
+
+	return template.HTML(fmt.Sprintf(
+		`<svg class='sparkline' width='%.0f' height='%.0f' viewBox='0 0 %.0f %.0f' role='img' aria-label='confidence over time'><polyline points='%s'/></svg>`,
+		width, height, width, height, strings.Join(points, " "),
+	))
+}
+
+// anchorFor returns the HTML ID of the prediction with the given ID.
+func anchorFor(id string) string {
+	return "pred-" + id
+}
+
+// hasAnchor returns true if the given prediction gets an HTML ID, which hashed and private predictions don’t in public output.
+func (o formattingOptions) hasAnchor(d streams.PredictionDocument) bool {
+	return d.ID != "" && o.shows(d) && !(o.forPublic && d.ShouldHash())
+}
+
 func markdownifyNotes(sts []streams.Stream) {
 	for _, st := range sts {
 		for i, d := range st.Predictions {
@@ -218,11 +440,31 @@ func addGuessData(pp *payload) {
 			continue
 		}
 
+		au := grouping.AnalysisUnit
+		if au.Scored() == 0 {
+			continue
+		}
+
 		p := Point{
 			*conf / 100,
-			grouping.AnalysisUnit.OfTotalCalled() / 100,
+			au.HitRate(),
 		}
 
+		low, high := au.WilsonInterval()
+
 		pp.GuessData = append(pp.GuessData, p)
+		pp.GuessIntervals = append(pp.GuessIntervals, Interval{X: p.X, Low: low, High: high})
+		pp.GuessRadii = append(pp.GuessRadii, pointRadius(au.Scored()))
 	}
 }
+
+// pointRadius returns how big, in pixels, a point on the calibration chart should be for a confidence level with n scored predictions.
+//
+// Area, not radius, grows in proportion to n, so a point for 50 predictions doesn’t swallow the chart. Past a point, it stops growing at all.
+func pointRadius(n int) float64 {
+	const (
+		minimum = 3.0
+		maximum = 15.0
+	)
+	return math.Min(maximum, minimum+2*math.Sqrt(float64(n)))
+}
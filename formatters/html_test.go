@@ -0,0 +1,72 @@
+// © 2019 Nathan Galt
+//
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package formatters
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/adiabatic/predictions/analyze"
+	"github.com/adiabatic/predictions/streams"
+)
+
+func TestPublicHTML(t *testing.T) {
+	var sts []streams.Stream
+	for _, text := range []string{publicStream, privateStream} {
+		st, err := streams.FromReader(strings.NewReader(text))
+		require.NoError(t, err)
+		sts = append(sts, st)
+	}
+
+	var buf strings.Builder
+	require.NoError(t, HTMLFromStreams(&buf, sts, ForPublic(true)))
+	public := buf.String()
+	assert.Contains(t, public, "I will ship the new release")
+	assert.Contains(t, public, "2 private predictions aren’t shown")
+	assert.NotContains(t, public, "dermatologist")
+	assert.NotContains(t, public, "Tag: doctors")
+	assert.NotContains(t, public, "not late enough")
+	assert.NotContains(t, public, "renovation")
+
+	buf.Reset()
+	require.NoError(t, HTMLFromStreams(&buf, sts, ForPublic(false)))
+	private := buf.String()
+	assert.Contains(t, private, "dermatologist")
+	assert.Contains(t, private, "not late enough")
+	assert.Contains(t, private, "renovation")
+}
+
+func TestTagTree(t *testing.T) {
+	adss := []analyze.AnalyzedDocuments{
+		{Labels: []string{"politics"}, AnalysisUnit: analyze.AnalysisUnit{Title: "Tag: politics"}},
+		{Labels: []string{"politics/us"}, AnalysisUnit: analyze.AnalysisUnit{Title: "Tag: politics/us"}},
+		{Labels: []string{"politics/us/senate"}, AnalysisUnit: analyze.AnalysisUnit{Title: "Tag: politics/us/senate"}},
+		{Labels: []string{"work"}, AnalysisUnit: analyze.AnalysisUnit{Title: "Tag: work"}},
+	}
+
+	tree := tagTree(adss)
+	require.Len(t, tree, 2)
+	assert.Equal(t, "Tag: politics", tree[0].Title)
+	assert.Empty(t, tree[0].Group.AnalysisUnit.Title, "the node has the title, so the group doesn’t need one")
+	assert.False(t, tree[0].Nested)
+	require.Len(t, tree[0].Children, 1)
+	assert.True(t, tree[0].Children[0].Nested)
+	require.Len(t, tree[0].Children[0].Children, 1)
+	assert.Equal(t, "Tag: politics/us/senate", tree[0].Children[0].Children[0].Title)
+	assert.Empty(t, tree[1].Children)
+}
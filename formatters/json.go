@@ -0,0 +1,245 @@
+// © 2019 Nathan Galt
+//
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package formatters
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+
+	"github.com/adiabatic/predictions/analyze"
+	"github.com/adiabatic/predictions/streams"
+)
+
+// JSONSchemaVersion is the version of the schema that JSONFromStreams’ output follows.
+//
+// Adding fields doesn’t change the version. Removing a field, renaming it, or changing what it means does. See doc/JSON.md for the schema itself.
+const JSONSchemaVersion = 1
+
+type jsonAnalysis struct {
+	SchemaVersion      int               `json:"schemaVersion"`
+	Everything         jsonGroup         `json:"everything"`
+	Groupings          []jsonGrouping    `json:"groupings"`
+	Conditional        jsonGroup         `json:"conditional"`
+	BrierDecomposition jsonDecomposition `json:"brierDecomposition"`
+}
+
+type jsonGrouping struct {
+	Name   string      `json:"name"`
+	Groups []jsonGroup `json:"groups"`
+}
+
+type jsonGroup struct {
+	Title       string           `json:"title"`
+	Labels      []string         `json:"labels,omitempty"`
+	Counts      jsonCounts       `json:"counts"`
+	Percentages jsonPercentages  `json:"percentages"`
+	Scores      jsonScores       `json:"scores"`
+	Predictions []jsonPrediction `json:"predictions"`
+}
+
+type jsonCounts struct {
+	Total      int `json:"total"`
+	Scored     int `json:"scored"`
+	Called     int `json:"called"`
+	Missed     int `json:"missed"`
+	Unscored   int `json:"unscored"`
+	Ongoing    int `json:"ongoing"`
+	Excluded   int `json:"excluded"`
+	Unscorable int `json:"unscorable"`
+}
+
+type jsonPercentages struct {
+	OfTotalScored      *float64 `json:"ofTotalScored"`
+	OfTotalCalled      *float64 `json:"ofTotalCalled"`
+	OfTotalMissed      *float64 `json:"ofTotalMissed"`
+	OfScoredCalled     *float64 `json:"ofScoredCalled"`
+	OfScoredMissed     *float64 `json:"ofScoredMissed"`
+	OfTotalUnscored    *float64 `json:"ofTotalUnscored"`
+	OfUnscoredOngoing  *float64 `json:"ofUnscoredOngoing"`
+	OfUnscoredExcluded *float64 `json:"ofUnscoredExcluded"`
+}
+
+type jsonScores struct {
+	Brier            *float64 `json:"brier"`
+	BrierSkill       *float64 `json:"brierSkill"`
+	Log              *float64 `json:"log"`
+	Spherical        *float64 `json:"spherical"`
+	CategoricalBrier *float64 `json:"categoricalBrier"`
+	CategoricalLog   *float64 `json:"categoricalLog"`
+	Interval         *float64 `json:"interval"`
+	IntervalCoverage *float64 `json:"intervalCoverage"`
+}
+
+type jsonDecomposition struct {
+	Reliability *float64 `json:"reliability"`
+	Resolution  *float64 `json:"resolution"`
+	Uncertainty *float64 `json:"uncertainty"`
+}
+
+type jsonPrediction struct {
+	ID         string        `json:"id,omitempty"`
+	Claim      string        `json:"claim"`
+	Confidence *float64      `json:"confidence"`
+	Outcomes   []jsonOutcome `json:"outcomes,omitempty"`
+	Happened   string        `json:"happened,omitempty"`
+	Low        *float64      `json:"low,omitempty"`
+	High       *float64      `json:"high,omitempty"`
+	Actual     *float64      `json:"actual,omitempty"`
+	Tags       []string      `json:"tags"`
+	Given      *jsonGiven    `json:"given,omitempty"`
+	Result     string        `json:"result"`
+	SourceFile string        `json:"sourceFile,omitempty"`
+}
+
+type jsonGiven struct {
+	ID       string `json:"id"`
+	Happened bool   `json:"happened"`
+}
+
+type jsonOutcome struct {
+	Name       string  `json:"name"`
+	Confidence float64 `json:"confidence"`
+}
+
+// jsonNumber returns nil for numbers that JSON can’t represent, like NaN and infinities, so they’re marshaled as null.
+func jsonNumber(f float64) *float64 {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil
+	}
+	return &f
+}
+
+// JSONFromStreams writes an analysis of the given streams, as JSON, to w.
+func JSONFromStreams(w io.Writer, sts []streams.Stream, options ...Option) error {
+	o := newFormattingOptions(options)
+	a := analyze.Analyze(o.withoutPrivateTags(sts), o.analysisOptions...)
+
+	groupings := make([]jsonGrouping, 0, len(a.Groupings))
+	for _, g := range a.Groupings {
+		jg := jsonGrouping{Name: g.Name, Groups: make([]jsonGroup, 0, len(g.Groups))}
+		for _, ads := range g.Groups {
+			jg.Groups = append(jg.Groups, o.jsonGroup(ads))
+		}
+		groupings = append(groupings, jg)
+	}
+
+	bd := a.BrierDecomposition()
+
+	ja := jsonAnalysis{
+		SchemaVersion: JSONSchemaVersion,
+		Everything:    o.jsonGroup(a.Everything),
+		Groupings:     groupings,
+		Conditional:   o.jsonGroup(a.Conditional),
+		BrierDecomposition: jsonDecomposition{
+			Reliability: jsonNumber(bd.Reliability),
+			Resolution:  jsonNumber(bd.Resolution),
+			Uncertainty: jsonNumber(bd.Uncertainty),
+		},
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(ja)
+}
+
+func (o formattingOptions) jsonGroup(ads analyze.AnalyzedDocuments) jsonGroup {
+	au := ads.AnalysisUnit
+
+	ret := jsonGroup{
+		Title:  au.Title,
+		Labels: ads.Labels,
+		Counts: jsonCounts{
+			Total:      au.Total(),
+			Scored:     au.Scored(),
+			Called:     au.Called,
+			Missed:     au.Missed,
+			Unscored:   au.Unscored(),
+			Ongoing:    au.Ongoing,
+			Excluded:   au.Excluded,
+			Unscorable: au.Unscorable,
+		},
+		Percentages: jsonPercentages{
+			OfTotalScored:      jsonNumber(au.OfTotalScored()),
+			OfTotalCalled:      jsonNumber(au.OfTotalCalled()),
+			OfTotalMissed:      jsonNumber(au.OfTotalMissed()),
+			OfScoredCalled:     jsonNumber(au.OfScoredCalled()),
+			OfScoredMissed:     jsonNumber(au.OfScoredMissed()),
+			OfTotalUnscored:    jsonNumber(au.OfTotalUnscored()),
+			OfUnscoredOngoing:  jsonNumber(au.OfUnscoredOngoing()),
+			OfUnscoredExcluded: jsonNumber(au.OfUnscoredExcluded()),
+		},
+		Scores: jsonScores{
+			Brier:      jsonNumber(au.BrierScore()),
+			BrierSkill: jsonNumber(au.BrierSkillScore()),
+			Log:        jsonNumber(au.LogScore()),
+			Spherical:  jsonNumber(au.SphericalScore()),
+
+			CategoricalBrier: jsonNumber(au.CategoricalBrierScore()),
+			CategoricalLog:   jsonNumber(au.CategoricalLogScore()),
+			Interval:         jsonNumber(au.IntervalScore()),
+			IntervalCoverage: jsonNumber(au.IntervalCoverage()),
+		},
+		Predictions: make([]jsonPrediction, 0, len(ads.Documents)),
+	}
+
+	for _, d := range ads.Documents {
+		if !o.shows(d) {
+			continue
+		}
+
+		jp := jsonPrediction{
+			Claim:      o.claim(d),
+			Confidence: d.Confidence,
+			Tags:       d.Tags,
+			Low:        d.Low,
+			High:       d.High,
+			Actual:     d.Actual,
+			ID:         d.ID,
+			Result:     jsonResult(d),
+			SourceFile: o.sourceFile(d),
+		}
+		if jp.Tags == nil {
+			jp.Tags = []string{}
+		}
+		for i, oc := range d.Outcomes {
+			jp.Outcomes = append(jp.Outcomes, jsonOutcome{Name: o.outcomeName(d, i), Confidence: oc.Confidence})
+		}
+		if d.IsCategorical() && d.IsResolved() {
+			jp.Happened = o.happenedOutcome(d)
+		}
+		if d.Given != nil {
+			jp.Given = &jsonGiven{ID: d.Given.ID, Happened: d.Given.Happened}
+		}
+		ret.Predictions = append(ret.Predictions, jp)
+	}
+
+	return ret
+}
+
+// jsonResult describes a prediction’s result with the same words used for its HTML class.
+func jsonResult(d streams.PredictionDocument) string {
+	if d.Claim == "" || (d.Confidence == nil && !d.IsCategorical()) {
+		return "unscorable"
+	}
+	if d.IsCategorical() && d.IsResolved() && d.Outcomes.Index(d.HappenedOutcome) < 0 {
+		return "unscorable"
+	}
+	if d.IsInterval() && (d.Low == nil || d.High == nil) {
+		return "unscorable"
+	}
+	class, _ := documentResult(d)
+	return class
+}
@@ -0,0 +1,85 @@
+// © 2019 Nathan Galt
+//
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package formatters
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/adiabatic/predictions/streams"
+)
+
+const jsonStream = `---
+title: Weekend plans
+scope: this weekend
+---
+claim: I will go hiking
+confidence: 70
+tags: [outdoors]
+happened: true
+---
+claim: I will clean the garage
+confidence: 40
+tags: [chores]
+`
+
+func TestJSONFromStreams(t *testing.T) {
+	st, err := streams.FromReader(strings.NewReader(jsonStream))
+	require.NoError(t, err)
+
+	var buf strings.Builder
+	require.NoError(t, JSONFromStreams(&buf, []streams.Stream{st}))
+
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(buf.String()), &decoded))
+
+	assert.Equal(t, float64(JSONSchemaVersion), decoded["schemaVersion"])
+
+	everything := decoded["everything"].(map[string]interface{})
+	predictions := everything["predictions"].([]interface{})
+	assert.Len(t, predictions, 2)
+	assert.Equal(t, "true-positive", predictions[0].(map[string]interface{})["result"])
+	assert.Equal(t, "ongoing", predictions[1].(map[string]interface{})["result"])
+
+	groupings := decoded["groupings"].([]interface{})
+	tags := groupings[1].(map[string]interface{})
+	assert.Equal(t, "tag", tags["name"])
+	chores := tags["groups"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, []interface{}{"chores"}, chores["labels"])
+	assert.Equal(t, "Tag: chores", chores["title"], "tags nobody put in a tag order are alphabetical")
+	assert.Nil(t, chores["scores"].(map[string]interface{})["brier"], "a group with nothing scored has no Brier score")
+}
+
+func TestJSONFromDiagnostics(t *testing.T) {
+	st, err := streams.FromReader(strings.NewReader("---\ntitle: Lint\n---\nclaim: I will go hiking\n"))
+	require.NoError(t, err)
+
+	var v streams.Validator
+	var buf strings.Builder
+	require.NoError(t, JSONFromDiagnostics(&buf, DiagnosticsFromErrors(v.RunAll(st))))
+
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(buf.String()), &decoded))
+
+	assert.Equal(t, float64(DiagnosticsSchemaVersion), decoded["schemaVersion"])
+	assert.Equal(t, float64(1), decoded["errors"])
+	diagnostic := decoded["diagnostics"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "error.confidence.missing", diagnostic["id"])
+	assert.Equal(t, float64(4), diagnostic["line"])
+}
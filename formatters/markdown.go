@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/adiabatic/predictions/analyze"
 	"github.com/adiabatic/predictions/streams"
 )
 
@@ -27,15 +28,22 @@ import (
 //
 // - ongoing predictions are plain
 //
-// - predictions that were called correctly are bold
+// - predictions that were called correctly are bold, and so are multiple-choice predictions where the outcome thought most likely happened and numeric-range predictions whose range covered what happened
 //
 // - predictions that were mis-called are struck through
 //
 // - excluded-for-cause predictions are italicized
 //
+// Claims that should be hashed are hashed if the ForPublic option is set.
+//
 // Note that MarkdownFromDocument also uses HTML for the italics and the strikethrough. This may be a problem in some contexts that allow markdown but not HTML, like some forum software in some configurations.
-func MarkdownFromDocument(d streams.PredictionDocument) string {
-	meat := fmt.Sprintf("%v: %v%%", d.Claim, *(d.Confidence))
+func MarkdownFromDocument(d streams.PredictionDocument, options ...Option) string {
+	o := newFormattingOptions(options)
+
+	meat := o.markdownMeat(d)
+	if d.IsConditional() {
+		meat += fmt.Sprintf(" (given that %s)", o.given(d))
+	}
 	withToppings := ""
 
 	switch Evaluate(d) {
@@ -43,9 +51,9 @@ func MarkdownFromDocument(d streams.PredictionDocument) string {
 		withToppings = fmt.Sprintf("- <i>%v</i>", meat)
 	case Ongoing:
 		withToppings = fmt.Sprintf("- %v", meat)
-	case CalledTruePositive, CalledTrueNegative:
+	case CalledTruePositive, CalledTrueNegative, CalledOutcome, CalledInterval:
 		withToppings = fmt.Sprintf("- <b>%v</b>", meat)
-	case MissedFalsePositive, MissedFalseNegative:
+	case MissedFalsePositive, MissedFalseNegative, MissedOutcome, MissedInterval:
 		withToppings = fmt.Sprintf("- <s>%v</s>", meat)
 	case Resolved:
 		withToppings = fmt.Sprintf("- <b><s>%v</s></b>", meat) // Ugly and confusing but I don’t see a better way at the moment
@@ -56,8 +64,69 @@ func MarkdownFromDocument(d streams.PredictionDocument) string {
 	return withToppings + "\n"
 }
 
+// markdownMeat returns a prediction’s claim and its confidence. For a multiple-choice prediction, it returns its claim, its outcomes with their confidences, and what happened. For a numeric-range prediction, it returns its claim, its range and confidence, and the actual value.
+func (o formattingOptions) markdownMeat(d streams.PredictionDocument) string {
+	if d.IsInterval() {
+		ret := fmt.Sprintf("%v: %v, %v", o.claim(d), intervalRange(d), confidenceText(d))
+		if d.IsResolved() {
+			ret += fmt.Sprintf(" (actual: %v)", *d.Actual)
+		}
+		return ret
+	}
+
+	if !d.IsCategorical() {
+		return fmt.Sprintf("%v: %v", o.claim(d), confidenceText(d))
+	}
+
+	ss := make([]string, 0, len(d.Outcomes))
+	for i, oc := range d.Outcomes {
+		ss = append(ss, fmt.Sprintf("%v %v%%", o.outcomeName(d, i), oc.Confidence))
+	}
+
+	ret := fmt.Sprintf("%v: %v", o.claim(d), strings.Join(ss, ", "))
+	if d.IsResolved() {
+		ret += fmt.Sprintf(" (happened: %v)", o.happenedOutcome(d))
+	}
+	return ret
+}
+
+// confidenceText returns a prediction’s confidence, like “70%”. If it’s been updated, every confidence it’s had is shown, like “60% → 70%”. A missing confidence is shown as a question mark.
+func confidenceText(d streams.PredictionDocument) string {
+	if len(d.Updates) == 0 {
+		if d.Confidence == nil {
+			return "?%"
+		}
+		return fmt.Sprintf("%v%%", *(d.Confidence))
+	}
+
+	history := d.ConfidenceHistory()
+	ss := make([]string, 0, len(history))
+	for _, p := range history {
+		ss = append(ss, fmt.Sprintf("%v%%", p.Confidence))
+	}
+	return strings.Join(ss, " → ")
+}
+
+// intervalRange returns a numeric-range prediction’s range, like “2–3”. A missing end is shown as a question mark.
+func intervalRange(d streams.PredictionDocument) string {
+	end := func(f *float64) string {
+		if f == nil {
+			return "?"
+		}
+		return fmt.Sprintf("%v", *f)
+	}
+	return end(d.Low) + "–" + end(d.High)
+}
+
 // MarkdownFromStream makes a markdown-formatted stream.
-func MarkdownFromStream(st streams.Stream) string {
+//
+// If the ForPublic option is set and the stream is private, it’s summarized instead of listed.
+func MarkdownFromStream(st streams.Stream, options ...Option) string {
+	o := newFormattingOptions(options)
+	if o.forPublic && st.Metadata.Private {
+		return o.privateSummary(st.Predictions)
+	}
+
 	var buf strings.Builder
 
 	for _, d := range st.Predictions {
@@ -65,53 +134,77 @@ func MarkdownFromStream(st streams.Stream) string {
 			continue
 		}
 
-		buf.WriteString(MarkdownFromDocument(d))
+		buf.WriteString(MarkdownFromDocument(d, options...))
 	}
 	return buf.String()
 }
 
-// MarkdownFromStreams makes a Markdown-formatted version of a slice of streams.
+// MarkdownFromStreams makes a Markdown-formatted version of a slice of streams: everything, then a section for each group of every grouping that was asked for.
 func MarkdownFromStreams(sts []streams.Stream, options ...Option) string {
-	var buf strings.Builder
+	o := newFormattingOptions(options)
+	sts = o.withoutPrivateTags(sts)
 
-	o := formattingOptions{}
-	for _, f := range options {
-		f(&o)
-	}
+	var buf strings.Builder
 
-	// TODO: first by title/scope, then by each individual tag…
 	buf.WriteString("# Everything\n\n")
 	for _, st := range sts {
-		buf.WriteString(MarkdownFromStream(st))
+		buf.WriteString(MarkdownFromStream(st, options...))
 	}
 
 	buf.WriteString("\n")
 
-	keysUsed := streams.KeysUsed(sts)
-	if len(keysUsed) > 1 {
-		for _, key := range keysUsed {
-			fmt.Fprintf(&buf, "# %s\n\n", key)
-
-			for _, d := range streams.DocumentsMatching(sts, streams.MatchingKey(key)) {
-				buf.WriteString(MarkdownFromDocument(d))
+	listing := append([]analyze.Option{analyze.DefaultGroupBy(analyze.ListingGroupings...)}, o.analysisOptions...)
+	for _, g := range analyze.Analyze(sts, listing...).InformativeGroupings() {
+		for _, ads := range g.Groups {
+			fmt.Fprintf(&buf, "%s\n\n", markdownHeader(g, ads))
+
+			hidden := make([]streams.PredictionDocument, 0)
+			for _, d := range ads.Documents {
+				if !o.shows(d) {
+					hidden = append(hidden, d)
+					continue
+				}
+				buf.WriteString(MarkdownFromDocument(d, options...))
 			}
+			buf.WriteString(o.privateSummary(hidden))
 
 			buf.WriteString("\n")
 		}
 	}
 
-	tagsUsed := streams.TagsUsed(sts)
-	if len(tagsUsed) > 0 {
-		for _, tag := range tagsUsed {
-			fmt.Fprintf(&buf, "# %s\n\n", tag)
+	return buf.String()
+}
 
-			for _, d := range streams.DocumentsMatching(sts, streams.MatchingTag(tag)) {
-				buf.WriteString(MarkdownFromDocument(d))
-			}
+// markdownHeader returns the header for a group. Tags are their own headers, and nested tags get smaller headers, down to the smallest one Markdown has.
+func markdownHeader(g analyze.Grouping, ads analyze.AnalyzedDocuments) string {
+	if g.Name != "tag" {
+		return "# " + ads.AnalysisUnit.Title
+	}
 
-			buf.WriteString("\n")
-		}
+	tag := ads.Labels[0]
+	level := 1
+	for parent := streams.ParentTag(tag); parent != "" && level < 6; parent = streams.ParentTag(parent) {
+		level++
 	}
+	return strings.Repeat("#", level) + " " + tag
+}
 
-	return buf.String()
+// privateSummary makes a Markdown list item that says how the given private predictions turned out without saying what any of them were, like “- 12 private predictions: 7 called, 3 missed, Brier score 0.1625”.
+func (o formattingOptions) privateSummary(ds []streams.PredictionDocument) string {
+	if len(ds) == 0 {
+		return ""
+	}
+
+	au := analyze.Analyze([]streams.Stream{{Predictions: ds}}, o.analysisOptions...).Everything.AnalysisUnit
+
+	what := fmt.Sprintf("%d private predictions", len(ds))
+	if len(ds) == 1 {
+		what = "1 private prediction"
+	}
+
+	ret := fmt.Sprintf("- %s: %d called, %d missed", what, au.Called, au.Missed)
+	if len(au.SquaredDifferences) > 0 {
+		ret += fmt.Sprintf(", Brier score %.4f", au.BrierScore())
+	}
+	return ret + "\n"
 }
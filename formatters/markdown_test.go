@@ -15,10 +15,13 @@
 package formatters
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/adiabatic/predictions/analyze"
 	"github.com/adiabatic/predictions/streams"
 )
 
@@ -52,3 +55,199 @@ func TestConfidencesUnder50Percent(t *testing.T) {
 		assert.Contains(t, s, aRow.containee)
 	}
 }
+
+func TestHashedClaimsOnlyForPublic(t *testing.T) {
+	confidence := 90.0
+	happened := false
+	d := streams.PredictionDocument{
+		Claim:      "Dennis will not change jobs",
+		Confidence: &confidence,
+		Happened:   &happened,
+		Hash:       true,
+		Salt:       "kkjskvjsdwolvkjsjv",
+	}
+
+	public := MarkdownFromDocument(d, ForPublic(true))
+	assert.Contains(t, public, "06b940c01111111849d11d0b90726f24a95e0ac2c5817ad1a49a0f298561adfb")
+	assert.NotContains(t, public, "Dennis")
+
+	private := MarkdownFromDocument(d, ForPublic(false))
+	assert.Contains(t, private, "Dennis will not change jobs")
+}
+
+func TestMultipleChoiceOutcomes(t *testing.T) {
+	d := streams.PredictionDocument{
+		Claim:           "Dennis will next work for",
+		Outcomes:        streams.Outcomes{{Name: "Apple", Confidence: 60}, {Name: "Google", Confidence: 40}},
+		HappenedOutcome: "Google",
+		Salt:            "kkjskvjsdwolvkjsjv",
+	}
+
+	private := MarkdownFromDocument(d)
+	assert.Equal(t, "- <s>Dennis will next work for: Apple 60%, Google 40% (happened: Google)</s>\n", private)
+
+	public := MarkdownFromDocument(d, ForPublic(true))
+	assert.NotContains(t, public, "Google", "outcomes can give away a hashed claim")
+	assert.Contains(t, public, "option 1 60%, option 2 40% (happened: option 2)")
+}
+
+func TestNumericRanges(t *testing.T) {
+	low, high, confidence, actual := 2.0, 3.0, 80.0, 3.5
+	d := streams.PredictionDocument{
+		Claim:      "Revenue in millions",
+		Low:        &low,
+		High:       &high,
+		Confidence: &confidence,
+		Actual:     &actual,
+	}
+
+	assert.Equal(t, "- <s>Revenue in millions: 2–3, 80% (actual: 3.5)</s>\n", MarkdownFromDocument(d))
+}
+
+const publicStream = `---
+title: Work
+---
+claim: I will ship the new release
+confidence: 80
+tags: [work]
+happened: true
+notes: The release was late, but not late enough to miss.
+---
+claim: I will move desks
+confidence: 60
+cause for exclusion: The office closed for renovation.
+`
+
+const privateStream = `---
+title: Health
+private: true
+---
+claim: I will see the dermatologist
+confidence: 70
+tags: [doctors]
+happened: true
+---
+claim: I will sleep eight hours a night
+confidence: 30
+happened: false
+`
+
+func TestPublicMarkdown(t *testing.T) {
+	var sts []streams.Stream
+	for _, text := range []string{publicStream, privateStream} {
+		st, err := streams.FromReader(strings.NewReader(text))
+		require.NoError(t, err)
+		sts = append(sts, st)
+	}
+
+	public := MarkdownFromStreams(sts, ForPublic(true))
+	assert.Contains(t, public, "I will ship the new release")
+	assert.Contains(t, public, "- 2 private predictions: 1 called, 1 missed, Brier score 0.0900\n")
+	assert.NotContains(t, public, "dermatologist")
+	assert.NotContains(t, public, "# doctors")
+
+	private := MarkdownFromStreams(sts, ForPublic(false))
+	assert.Contains(t, private, "dermatologist")
+	assert.Contains(t, private, "# doctors")
+}
+
+func TestMarkdownGroupBy(t *testing.T) {
+	st, err := streams.FromReader(strings.NewReader(`---
+title: Years
+---
+claim: I will ship the app
+confidence: 80
+tags: [work]
+made on: 2018-03-01
+happened: true
+---
+claim: I will run a marathon
+confidence: 60
+tags: [health]
+made on: 2019-04-01
+happened: true
+`))
+	require.NoError(t, err)
+
+	dims, err := analyze.ParseGrouping("year")
+	require.NoError(t, err)
+
+	md := MarkdownFromStreams([]streams.Stream{st})
+	assert.Contains(t, md, "# work\n")
+	assert.NotContains(t, md, "confidence level", "lists should only be grouped by key and tag unless asked otherwise")
+
+	md = MarkdownFromStreams([]streams.Stream{st}, AnalysisOptions(analyze.GroupBy(dims...)))
+	assert.Contains(t, md, "# Made in 2018\n\n- <b>I will ship the app: 80%</b>\n")
+	assert.Contains(t, md, "# Made in 2019\n\n- <b>I will run a marathon: 60%</b>\n")
+	assert.NotContains(t, md, "# work")
+}
+
+func TestMarkdownWithoutConfidence(t *testing.T) {
+	st, err := streams.FromReader(strings.NewReader(`---
+title: Ranges
+---
+claim: Books read
+low: 12
+high: 20
+`))
+	require.NoError(t, err)
+
+	assert.Equal(t, "- Books read: 12–20, ?%\n", MarkdownFromDocument(st.Predictions[0]))
+}
+
+func TestStatisticsCountOnlyYesOrNo(t *testing.T) {
+	st, err := streams.FromReader(strings.NewReader(`---
+title: Mixed
+---
+claim: I will fix the fence
+confidence: 70
+happened: true
+---
+claim: Who will win the bake-off?
+outcomes: {Alice: 60, Bob: 40}
+happened: Alice
+---
+claim: Books read
+low: 12
+high: 20
+confidence: 80
+actual: 15
+`))
+	require.NoError(t, err)
+
+	stats := MarkdownStatisticsFromStreams([]streams.Stream{st})
+	assert.Contains(t, stats, "| Everything | 1 | 1 | 0 | 0.0900 |")
+}
+
+func TestMarkdownResolvedWithoutConfidence(t *testing.T) {
+	st, err := streams.FromReader(strings.NewReader(`---
+title: Unsure
+---
+claim: I will fix the fence
+tags: [chores]
+happened: true
+---
+claim: I will paint the shed
+confidence: 60
+tags: [chores]
+`))
+	require.NoError(t, err)
+
+	assert.Equal(t, "- <b><s>I will fix the fence: ?%</s></b>\n", MarkdownFromDocument(st.Predictions[0]))
+	assert.NotPanics(t, func() { MarkdownFromStreams([]streams.Stream{st}) })
+	assert.NotPanics(t, func() { MarkdownStatisticsFromStreams([]streams.Stream{st}) })
+}
+
+func TestMarkdownResolvedButExcluded(t *testing.T) {
+	st, err := streams.FromReader(strings.NewReader(`---
+title: Excluded
+---
+claim: The meeting will run long
+confidence: 20
+cause for exclusion: it was canceled
+happened: false
+`))
+	require.NoError(t, err)
+
+	assert.Equal(t, "- <i>The meeting will run long: 20%</i>\n", MarkdownFromDocument(st.Predictions[0]))
+}
@@ -28,7 +28,7 @@ const (
 	// Ongoing is used to describe a prediction that hasn’t been resolved yet.
 	Ongoing
 
-	// Resolved is used to describe a prediction that can be evaluated, but cannot be classified as “called” or “missed” because it predicts something will happen at a 50% confidence interval.
+	// Resolved is used to describe a prediction that can be evaluated, but cannot be classified as “called” or “missed” because it predicts something will happen at a 50% confidence interval, or because it has no confidence at all.
 	Resolved
 
 	//Called
@@ -46,15 +46,41 @@ const (
 
 	// MissedFalseNegative is used to describe a prediction where something was predicted to not happen, but happened anyway.
 	MissedFalseNegative
+
+	// CalledOutcome is used to describe a multiple-choice prediction where the outcome thought most likely (or tied for most likely) happened.
+	CalledOutcome
+
+	// MissedOutcome is used to describe a multiple-choice prediction where an outcome that wasn’t thought most likely happened.
+	MissedOutcome
+
+	// CalledInterval is used to describe a numeric-range prediction where the actual value fell within the range.
+	CalledInterval
+
+	// MissedInterval is used to describe a numeric-range prediction where the actual value fell outside the range.
+	MissedInterval
 )
 
 // Evaluate returns an int describing whether the prediction is excluded, ongoing, called, or missed.
 func Evaluate(d streams.PredictionDocument) int {
+	if d.ConditionFailed {
+		return ExcludedForCause
+	}
+
+	if d.IsCategorical() {
+		return evaluateCategorical(d)
+	}
+
+	if d.IsInterval() {
+		return evaluateInterval(d)
+	}
+
 	switch {
-	case d.Happened == nil && d.CauseForExclusion != "": // must come before d.Happened checks
+	case d.CauseForExclusion != "": // must come before d.Happened checks
 		return ExcludedForCause
 	case d.Happened == nil:
 		return Ongoing
+	case d.Confidence == nil: // lint complains about these, but they still have to be shown
+		return Resolved
 	case (*d.Happened == true || *d.Happened == false) && d.Confidence != nil && *d.Confidence == 50:
 		// Yes, everything in parentheses above is redundant but it communicates intent
 		return Resolved
@@ -75,3 +101,39 @@ func Evaluate(d streams.PredictionDocument) int {
 		panic(fmt.Sprintf("logic error in formatters.Evaluate given document: %s", scs.Sdump(d)))
 	}
 }
+
+// evaluateCategorical is Evaluate for multiple-choice predictions.
+//
+// A prediction that says an outcome happened that it doesn’t list is Resolved, since there’s no telling whether it was called or missed.
+func evaluateCategorical(d streams.PredictionDocument) int {
+	switch {
+	case d.CauseForExclusion != "":
+		return ExcludedForCause
+	case !d.IsResolved():
+		return Ongoing
+	case d.Outcomes.Index(d.HappenedOutcome) < 0:
+		return Resolved
+	case d.Outcomes.IsFavorite(d.HappenedOutcome):
+		return CalledOutcome
+	default:
+		return MissedOutcome
+	}
+}
+
+// evaluateInterval is Evaluate for numeric-range predictions.
+//
+// A prediction with only half an interval is Resolved once it has an actual value, since there’s no telling whether it was called or missed.
+func evaluateInterval(d streams.PredictionDocument) int {
+	switch {
+	case d.CauseForExclusion != "":
+		return ExcludedForCause
+	case !d.IsResolved():
+		return Ongoing
+	case d.Low == nil || d.High == nil:
+		return Resolved
+	case d.Covered():
+		return CalledInterval
+	default:
+		return MissedInterval
+	}
+}
@@ -14,16 +14,139 @@
 
 package formatters
 
+import (
+	"fmt"
+
+	"github.com/adiabatic/predictions/analyze"
+	"github.com/adiabatic/predictions/streams"
+)
+
 // Option is the type used for public-facing formatting options.
 type Option func(o *formattingOptions)
 
-// ForPublic is an option that says whether to sanitize the output for public consumption.
+// ForPublic is an option that says whether to sanitize the output for public consumption. Anything that could give away what a hashed or private prediction is about, not just its claim, is hidden.
 func ForPublic(b bool) Option {
 	return func(o *formattingOptions) {
 		o.forPublic = b
 	}
 }
 
+// AnalysisOptions is an option that passes options along to analyze.Analyze.
+func AnalysisOptions(options ...analyze.Option) Option {
+	return func(o *formattingOptions) {
+		o.analysisOptions = append(o.analysisOptions, options...)
+	}
+}
+
 type formattingOptions struct {
-	forPublic bool
+	forPublic       bool
+	analysisOptions []analyze.Option
+}
+
+func newFormattingOptions(options []Option) formattingOptions {
+	o := formattingOptions{}
+	for _, f := range options {
+		f(&o)
+	}
+	return o
+}
+
+// claim returns the claim as it should be shown, given the formatting options.
+//
+// Claims that should be hashed are only hashed for public consumption. You already know what you predicted.
+func (o formattingOptions) claim(d streams.PredictionDocument) string {
+	if o.forPublic {
+		return d.PublicClaim()
+	}
+	return d.Claim
+}
+
+// given describes the condition of a conditional prediction, like “I will get a new job” happens, hashing the claim it depends on if need be.
+func (o formattingOptions) given(d streams.PredictionDocument) string {
+	if d.Given == nil {
+		return ""
+	}
+
+	what := fmt.Sprintf("the prediction with ID “%s”", d.Given.ID)
+	if d.Given.Target != nil {
+		what = fmt.Sprintf("“%s”", o.claim(*d.Given.Target))
+		if !o.shows(*d.Given.Target) {
+			what = "a private prediction"
+		}
+	}
+
+	if d.Given.Happened {
+		return what + " happens"
+	}
+	return what + " doesn’t happen"
+}
+
+// outcomeName returns the name of the receiver’s ith outcome as it should be shown, given the formatting options. Outcomes of hashed predictions are only numbered for public consumption.
+func (o formattingOptions) outcomeName(d streams.PredictionDocument, i int) string {
+	if o.forPublic && d.ShouldHash() {
+		return fmt.Sprintf("option %d", i+1)
+	}
+	return d.Outcomes[i].Name
+}
+
+// happenedOutcome returns the name of the outcome that happened as it should be shown, given the formatting options.
+func (o formattingOptions) happenedOutcome(d streams.PredictionDocument) string {
+	if i := d.Outcomes.Index(d.HappenedOutcome); i >= 0 {
+		return o.outcomeName(d, i)
+	}
+	if o.forPublic && d.ShouldHash() {
+		return "an unlisted option"
+	}
+	return d.HappenedOutcome
+}
+
+// shows returns true if the prediction should be shown at all, given the formatting options.
+func (o formattingOptions) shows(d streams.PredictionDocument) bool {
+	return !(o.forPublic && d.IsPrivate())
+}
+
+// notes returns the prediction’s notes as they should be shown, given the formatting options.
+func (o formattingOptions) notes(d streams.PredictionDocument) string {
+	if o.forPublic {
+		return ""
+	}
+	return d.Notes
+}
+
+// causeForExclusion returns why the prediction was excluded as it should be shown, given the formatting options.
+func (o formattingOptions) causeForExclusion(d streams.PredictionDocument) string {
+	if o.forPublic {
+		return ""
+	}
+	return d.CauseForExclusion
+}
+
+// sourceFile returns the name of the file the prediction came from, given the formatting options.
+func (o formattingOptions) sourceFile(d streams.PredictionDocument) string {
+	if o.forPublic || d.Parent == nil {
+		return ""
+	}
+	return d.Parent.FromFilename
+}
+
+// withoutPrivateTags returns copies of the given streams without the tags of private streams’ predictions, if the output is for public consumption.
+func (o formattingOptions) withoutPrivateTags(sts []streams.Stream) []streams.Stream {
+	if !o.forPublic {
+		return sts
+	}
+
+	ret := make([]streams.Stream, len(sts))
+	for i, st := range sts {
+		ret[i] = st
+		if !st.Metadata.Private {
+			continue
+		}
+
+		ret[i].Predictions = make([]streams.PredictionDocument, len(st.Predictions))
+		for j, d := range st.Predictions {
+			d.Tags = nil
+			ret[i].Predictions[j] = d
+		}
+	}
+	return ret
 }
@@ -0,0 +1,146 @@
+// © 2019 Nathan Galt
+//
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package formatters
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/adiabatic/predictions/analyze"
+	"github.com/adiabatic/predictions/streams"
+)
+
+// MarkdownStatisticsFromStreams makes a Markdown table of scores for the given streams, one row for everything and one for each group of every grouping that was asked for.
+func MarkdownStatisticsFromStreams(sts []streams.Stream, options ...Option) string {
+	o := newFormattingOptions(options)
+	a := analyze.Analyze(sts, o.analysisOptions...)
+
+	var buf strings.Builder
+
+	buf.WriteString("# Statistics\n\n")
+	buf.WriteString("| | Scored | Called | Missed | Brier score | Brier skill score | Log score | Spherical score |\n")
+	buf.WriteString("| --- | ---: | ---: | ---: | ---: | ---: | ---: | ---: |\n")
+
+	groupings := a.InformativeGroupings()
+
+	writeStatisticsRow(&buf, a.Everything)
+
+	for _, g := range groupings {
+		for _, ads := range g.Groups {
+			// Groups with nothing but multiple-choice and numeric-range predictions only get rows in the tables for them.
+			if hasYesOrNo(ads) {
+				writeStatisticsRow(&buf, ads)
+			}
+		}
+	}
+
+	if len(a.Conditional.Documents) > 0 {
+		writeStatisticsRow(&buf, a.Conditional)
+	}
+
+	if len(a.Everything.AnalysisUnit.CategoricalForecasts) > 0 {
+		buf.WriteString("\n## Multiple-choice predictions\n\n")
+		buf.WriteString("| | Scored | Brier score | Log score |\n")
+		buf.WriteString("| --- | ---: | ---: | ---: |\n")
+
+		for _, adss := range groupsOf(a.Everything, groupings) {
+			for _, ads := range adss {
+				if len(ads.AnalysisUnit.CategoricalForecasts) > 0 {
+					writeCategoricalStatisticsRow(&buf, ads)
+				}
+			}
+		}
+
+		buf.WriteString("\nThe scores in the table above are only for yes-or-no predictions. Multiple-choice Brier scores add up the squared differences for every option, so they range from 0 (best) to 2 (worst).\n")
+	}
+
+	if len(a.Everything.AnalysisUnit.IntervalForecasts) > 0 {
+		buf.WriteString("\n## Numeric-range predictions\n\n")
+		buf.WriteString("| | Scored | Coverage | Interval score |\n")
+		buf.WriteString("| --- | ---: | ---: | ---: |\n")
+
+		for _, adss := range groupsOf(a.Everything, groupings) {
+			for _, ads := range adss {
+				if len(ads.AnalysisUnit.IntervalForecasts) > 0 {
+					writeIntervalStatisticsRow(&buf, ads)
+				}
+			}
+		}
+
+		buf.WriteString("\nCoverage is how often the actual value fell within the range; a well-calibrated 80% range covers it about 80% of the time. The interval score is the range’s width plus a penalty for misses that grows with how far off they were. Lower is better, and it’s in whatever units the predictions were in.\n")
+	}
+
+	bd := a.BrierDecomposition()
+	buf.WriteString("\n## Brier score decomposition\n\n")
+	buf.WriteString("| Reliability | Resolution | Uncertainty | Brier score |\n")
+	buf.WriteString("| ---: | ---: | ---: | ---: |\n")
+	fmt.Fprintf(&buf, "| %.4f | %.4f | %.4f | %.4f |\n\n", bd.Reliability, bd.Resolution, bd.Uncertainty, bd.BrierScore())
+	buf.WriteString("Reliability is how far your hit rate at each confidence level is from that confidence level (lower is better). Resolution is how far your hit rate at each confidence level is from your overall hit rate (higher is better). Uncertainty depends only on how often things happened. The Brier score is reliability − resolution + uncertainty.\n")
+
+	return buf.String()
+}
+
+// groupsOf returns everything, then the groups of each of the given groupings.
+func groupsOf(everything analyze.AnalyzedDocuments, groupings []analyze.Grouping) [][]analyze.AnalyzedDocuments {
+	ret := [][]analyze.AnalyzedDocuments{{everything}}
+	for _, g := range groupings {
+		ret = append(ret, g.Groups)
+	}
+	return ret
+}
+
+// hasYesOrNo returns true if any of the given predictions is a yes-or-no prediction.
+func hasYesOrNo(ads analyze.AnalyzedDocuments) bool {
+	for _, d := range ads.Documents {
+		if d.IsBinary() {
+			return true
+		}
+	}
+	return false
+}
+
+func writeStatisticsRow(buf *strings.Builder, ads analyze.AnalyzedDocuments) {
+	au := ads.AnalysisUnit
+	fmt.Fprintf(buf, "| %s | %d | %d | %d | %.4f | %.4f | %.4f | %.4f |\n",
+		au.Title,
+		len(au.Forecasts),
+		au.YesOrNoCalled(),
+		au.YesOrNoMissed(),
+		au.BrierScore(),
+		au.BrierSkillScore(),
+		au.LogScore(),
+		au.SphericalScore(),
+	)
+}
+
+func writeCategoricalStatisticsRow(buf *strings.Builder, ads analyze.AnalyzedDocuments) {
+	au := ads.AnalysisUnit
+	fmt.Fprintf(buf, "| %s | %d | %.4f | %.4f |\n",
+		au.Title,
+		len(au.CategoricalForecasts),
+		au.CategoricalBrierScore(),
+		au.CategoricalLogScore(),
+	)
+}
+
+func writeIntervalStatisticsRow(buf *strings.Builder, ads analyze.AnalyzedDocuments) {
+	au := ads.AnalysisUnit
+	fmt.Fprintf(buf, "| %s | %d | %.2f%% | %.4f |\n",
+		au.Title,
+		len(au.IntervalForecasts),
+		100*au.IntervalCoverage(),
+		au.IntervalScore(),
+	)
+}
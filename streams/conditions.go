@@ -0,0 +1,101 @@
+// © 2019 Nathan Galt
+//
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package streams
+
+import (
+	"fmt"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// A Condition names the prediction that a conditional prediction depends on, and how that prediction has to turn out for the conditional prediction to count.
+type Condition struct {
+	ID       string
+	Happened bool
+
+	// Target is the yes-or-no prediction with the condition’s ID. It’s nil until conditions are resolved, and stays nil if there’s no such prediction.
+	Target *PredictionDocument `yaml:"-"`
+}
+
+// UnmarshalYAML decodes a “given” value, which is either an ID by itself, meaning that the prediction with that ID has to happen, or a mapping with “id” and “happened” keys.
+func (c *Condition) UnmarshalYAML(n *yaml.Node) error {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		c.Happened = true
+		return n.Decode(&c.ID)
+	case yaml.MappingNode:
+		var v struct {
+			ID       string
+			Happened *bool
+		}
+		if err := n.Decode(&v); err != nil {
+			return err
+		}
+		c.ID = v.ID
+		c.Happened = v.Happened == nil || *v.Happened
+		return nil
+	}
+	return fmt.Errorf("line %d: given must be an ID or a mapping with id and happened keys", n.Line)
+}
+
+// MarshalYAML encodes the receiver as an ID by itself if the prediction with that ID has to happen, or as a mapping with “id” and “happened” keys if it has to not happen.
+func (c Condition) MarshalYAML() (interface{}, error) {
+	if c.Happened {
+		return c.ID, nil
+	}
+	return &yaml.Node{Kind: yaml.MappingNode, Style: yaml.FlowStyle, Content: []*yaml.Node{
+		{Kind: yaml.ScalarNode, Value: "id"},
+		{Kind: yaml.ScalarNode, Tag: "!!str", Value: c.ID},
+		{Kind: yaml.ScalarNode, Value: "happened"},
+		{Kind: yaml.ScalarNode, Value: "false"},
+	}}, nil
+}
+
+// IsConditional returns true if the receiver only counts if another prediction turns out a certain way.
+func (d *PredictionDocument) IsConditional() bool {
+	if d == nil {
+		return false
+	}
+
+	return d.Given != nil
+}
+
+// resolveConditions finds the target of every condition in the given streams and marks every prediction whose condition failed.
+//
+// It’s run over everything that’s read at once, so a prediction can be conditional on a prediction in another file.
+func resolveConditions(sts []Stream) {
+	byID := make(map[string]*PredictionDocument)
+	for i := range sts {
+		for j := range sts[i].Predictions {
+			pred := &sts[i].Predictions[j]
+			if _, ok := byID[pred.ID]; pred.ID != "" && pred.IsBinary() && !ok {
+				byID[pred.ID] = pred
+			}
+		}
+	}
+
+	for i := range sts {
+		for j := range sts[i].Predictions {
+			pred := &sts[i].Predictions[j]
+			if pred.Given == nil {
+				continue
+			}
+
+			target := byID[pred.Given.ID]
+			pred.Given.Target = target
+			pred.ConditionFailed = target != nil && target.Happened != nil && *target.Happened != pred.Given.Happened
+		}
+	}
+}
@@ -0,0 +1,116 @@
+// © 2019 Nathan Galt
+//
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package streams
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// A rawDocument is the text of one YAML document, exactly as it appears in its stream, plus the line it starts on.
+type rawDocument struct {
+	text []byte
+	line int // starts at 1
+}
+
+// isDocumentMarker returns true if a line starts a new YAML document.
+//
+// YAML doesn’t allow “---” at the start of a line to mean anything else, not even inside a block scalar, so this is all it takes to find where documents start.
+func isDocumentMarker(line []byte) bool {
+	if !bytes.HasPrefix(line, []byte("---")) {
+		return false
+	}
+	rest := line[3:]
+	return len(rest) == 0 || rest[0] == ' ' || rest[0] == '\t' || rest[0] == '\r' || rest[0] == '\n'
+}
+
+// splitDocuments splits a stream into documents at each “---” line.
+//
+// Nothing is lost: concatenating the texts of everything returned gets you exactly what went in. The first rawDocument holds everything before the first “---”, and it may not have any YAML in it at all.
+func splitDocuments(b []byte) []rawDocument {
+	ret := []rawDocument{{line: 1}}
+	line := 1
+
+	for len(b) > 0 {
+		end := bytes.IndexByte(b, '\n') + 1
+		if end == 0 {
+			end = len(b)
+		}
+
+		if isDocumentMarker(b[:end]) {
+			ret = append(ret, rawDocument{line: line})
+		}
+
+		last := &ret[len(ret)-1]
+		last.text = append(last.text, b[:end]...)
+
+		b = b[end:]
+		line++
+	}
+
+	return ret
+}
+
+// node parses the receiver into a document node. It returns io.EOF if there’s no document in it, which happens when there’s nothing but comments and whitespace before the first “---”.
+//
+// Every line before the receiver’s first line is stood in for by an empty line so line numbers, both in the node and in error messages, count from the start of the stream.
+func (rd rawDocument) node() (*yaml.Node, error) {
+	padded := strings.Repeat("\n", rd.line-1) + string(rd.text)
+
+	var n yaml.Node
+	err := yaml.NewDecoder(strings.NewReader(padded)).Decode(&n)
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// A parsedDocument is a document node, or the error that kept it from being parsed, along with the line it starts on and the index of the rawDocument it came from.
+type parsedDocument struct {
+	node *yaml.Node
+	line int
+	raw  int
+	err  error
+}
+
+// position returns where the document starts. See positionOf.
+func (pd parsedDocument) position(filename string, document int) Position {
+	if pd.node == nil {
+		return Position{Filename: filename, Document: document, Line: pd.line, Column: 1}
+	}
+	return positionOf(pd.node, filename, document)
+}
+
+// parseDocuments parses every document in a stream. A document that can’t be parsed doesn’t keep the ones after it from being parsed.
+func parseDocuments(rds []rawDocument) []parsedDocument {
+	ret := make([]parsedDocument, 0, len(rds))
+	for i, rd := range rds {
+		n, err := rd.node()
+		if err == io.EOF {
+			if i == 0 {
+				continue
+			}
+			// “---” followed by nothing at all is an empty document, not the absence of one
+			n, err = &yaml.Node{Kind: yaml.DocumentNode, Line: rd.line, Column: 1}, nil
+		}
+
+		ret = append(ret, parsedDocument{node: n, line: rd.line, raw: i, err: err})
+	}
+
+	return ret
+}
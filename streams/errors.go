@@ -15,24 +15,78 @@
 package streams
 
 import (
-	"errors"
 	"fmt"
+	"strings"
 )
 
-// NB: The term “error” here is overloaded. I call everything in here an error even though, to the user, some are errors and some are warnings.
+// NB: The term “error” here is overloaded. I call everything in here an error even though, to the user, some are errors and some are warnings. Problem.Severity tells them apart.
+
+// A Severity says whether a Problem is an error or merely a warning.
+type Severity int
+
+// Severities, from least to most severe.
+const (
+	Warning Severity = iota
+	Error
+)
+
+func (sev Severity) String() string {
+	if sev == Warning {
+		return "warning"
+	}
+	return "error"
+}
+
+// A Problem is an error with an identifier, like “error.claim.missing”, that says what kind of error it is.
+//
+// Identifiers that start with “warn.” are warnings; everything else is an error. doc/ERRORS.md explains each identifier.
+type Problem struct {
+	ID       string
+	Position Position // where the document with the problem starts
+	Index    int      // index of the prediction with the problem, or -1 if the problem is with the metadata document or the stream as a whole
+	Message  string
+}
+
+// Error returns the problem as “file.yaml:123:1: [error.claim.missing] …”.
+func (p *Problem) Error() string {
+	return p.Position.String() + ": [" + p.ID + "] " + p.Message
+}
+
+// Severity returns Warning if the receiver’s identifier starts with “warn.” and Error otherwise.
+func (p *Problem) Severity() Severity {
+	if strings.HasPrefix(p.ID, "warn.") {
+		return Warning
+	}
+	return Error
+}
+
+// SeverityOf returns the severity of err. Errors that aren’t Problems are always errors.
+func SeverityOf(err error) Severity {
+	if p, ok := err.(*Problem); ok {
+		return p.Severity()
+	}
+	return Error
+}
+
+func newMetadataProblem(md MetadataDocument, id, format string, a ...interface{}) *Problem {
+	return &Problem{
+		ID:       id,
+		Position: md.Position,
+		Index:    -1,
+		Message:  fmt.Sprintf(format, a...),
+	}
+}
+
+// literal escapes percent signs in s so it can be put in the meme passed to makePredictionErrorMaker, which is used as a format string.
+func literal(s string) string {
+	return strings.ReplaceAll(s, "%", "%%")
+}
 
 // A PredictionErrorMaker takes a Stream and an index and returns an error. The index is meant to be the index of the prediction, so the first prediction is referred to with a zero index.
 type PredictionErrorMaker func(Stream, int) error
 
 func makePredictionErrorMaker(id, meme string) PredictionErrorMaker {
 	return func(s Stream, i int) error {
-		prefix := ""
-		if s.FromFilename != "" {
-			prefix = s.FromFilename + ": "
-		}
-
-		prefix += "[" + id + "]: "
-
 		claim := s.Predictions[i].Claim
 		previousClaim := ""
 		if i > 0 {
@@ -44,29 +98,27 @@ func makePredictionErrorMaker(id, meme string) PredictionErrorMaker {
 		atPrev := "prediction after prediction with claim “%v” " + meme
 		huh := "prediction exists that " + meme + "; neither it nor its predecessor have a claim"
 
+		p := &Problem{ID: id, Position: s.Predictions[i].Position, Index: i}
+
 		switch {
 		case i == 0:
-			return fmt.Errorf(prefix+first, claim)
+			p.Message = fmt.Sprintf(first, claim)
 		case claim != "":
-			return fmt.Errorf(prefix+at, claim)
+			p.Message = fmt.Sprintf(at, claim)
 		case previousClaim != "":
-			return fmt.Errorf(prefix+atPrev, previousClaim)
+			p.Message = fmt.Sprintf(atPrev, previousClaim)
 		default:
-			return errors.New(prefix + huh)
+			p.Message = fmt.Sprintf(huh)
 		}
+
+		return p
 	}
 }
 
 // NewErrorClaimMissing returns an error that describes the approximate location of a prediction that has no claim.
 func NewErrorClaimMissing(s Stream, i int) error {
 	// While I’d love to use makePredictionErrorMaker instead of mostly reimplementing it, makePredictionErrorMaker pinpoints errors by claim location. What, then, could it say about predictions that have no claim?
-	prefix := ""
-	if s.FromFilename != "" {
-		prefix = s.FromFilename + ": "
-	}
-
-	const id = "error.claim.missing"
-	prefix += "[" + id + "]: "
+	p := &Problem{ID: "error.claim.missing", Position: s.Predictions[i].Position, Index: i}
 
 	previousClaim := ""
 	if i > 0 {
@@ -75,12 +127,14 @@ func NewErrorClaimMissing(s Stream, i int) error {
 
 	switch {
 	case i == 0:
-		return fmt.Errorf(prefix + "first prediction has no claim")
+		p.Message = "first prediction has no claim"
 	case previousClaim != "":
-		return fmt.Errorf(prefix+"claim after “%v” has no claim", previousClaim)
+		p.Message = fmt.Sprintf("claim after “%v” has no claim", previousClaim)
 	default:
-		return fmt.Errorf(prefix + "prediction exists that has no claim, and neither does the one before it")
+		p.Message = "prediction exists that has no claim, and neither does the one before it"
 	}
+
+	return p
 }
 
 // Error makers
@@ -116,3 +170,123 @@ func NewErrorConfidenceUnity(s Stream, i int) error {
 		"has a confidence level of one",
 	)(s, i)
 }
+
+// NewErrorIntervalIncomplete returns an error describing a numeric-range prediction that has a low or a high, but not both.
+func NewErrorIntervalIncomplete(s Stream, i int) error {
+	return makePredictionErrorMaker(
+		"error.interval.incomplete",
+		"has only one of “low” and “high”",
+	)(s, i)
+}
+
+// NewErrorIntervalBackwards returns an error describing a numeric-range prediction whose low is above its high.
+func NewErrorIntervalBackwards(s Stream, i int) error {
+	return makePredictionErrorMaker(
+		"error.interval.backwards",
+		"has a low above its high",
+	)(s, i)
+}
+
+// NewErrorIntervalCertain returns an error describing a numeric-range prediction with a confidence level of 100%, which no interval score can be given for.
+func NewErrorIntervalCertain(s Stream, i int) error {
+	return makePredictionErrorMaker(
+		"error.interval.certain",
+		"is a numeric range with a confidence level of 100",
+	)(s, i)
+}
+
+// NewErrorSeriesNonMonotonic returns an error describing a prediction in a series that’s more confident than the earlier prediction at index j.
+func NewErrorSeriesNonMonotonic(s Stream, i, j int) error {
+	return makePredictionErrorMaker(
+		"warn.series.non-monotonic",
+		fmt.Sprintf("is more confident than “%v”, which comes before it in series “%v”", literal(s.Predictions[j].Claim), literal(s.Predictions[i].Series)),
+	)(s, i)
+}
+
+// NewErrorSeriesInconsistentOutcomes returns an error describing a prediction in a series that happened even though the earlier prediction at index j didn’t.
+func NewErrorSeriesInconsistentOutcomes(s Stream, i, j int) error {
+	return makePredictionErrorMaker(
+		"error.series.inconsistent-outcomes",
+		fmt.Sprintf("happened, but “%v”, which comes before it in series “%v”, didn’t", literal(s.Predictions[j].Claim), literal(s.Predictions[i].Series)),
+	)(s, i)
+}
+
+// NewErrorConditionUnknown returns an error describing a conditional prediction that’s conditional on a prediction that couldn’t be found.
+func NewErrorConditionUnknown(s Stream, i int) error {
+	return makePredictionErrorMaker(
+		"warn.given.unknown",
+		fmt.Sprintf("is given “%v”, but no yes-or-no prediction has that ID", literal(s.Predictions[i].Given.ID)),
+	)(s, i)
+}
+
+// NewErrorDueBeforeMadeOn returns an error describing a prediction that was due before it was made.
+func NewErrorDueBeforeMadeOn(s Stream, i int) error {
+	return makePredictionErrorMaker(
+		"error.due.before-made-on",
+		"is due before it was made",
+	)(s, i)
+}
+
+// NewErrorResolvedBeforeMadeOn returns an error describing a prediction that was resolved before it was made.
+func NewErrorResolvedBeforeMadeOn(s Stream, i int) error {
+	return makePredictionErrorMaker(
+		"error.resolved-on.before-made-on",
+		"was resolved before it was made",
+	)(s, i)
+}
+
+// NewErrorUpdateConfidenceMissing returns an error describing a prediction with an update that has no confidence level.
+func NewErrorUpdateConfidenceMissing(s Stream, i int) error {
+	return makePredictionErrorMaker(
+		"error.update.confidence-missing",
+		"has an update with no confidence level",
+	)(s, i)
+}
+
+// NewErrorUpdateConfidenceImpossible returns an error describing a prediction with an update that has a confidence level below 0% or above 100%.
+func NewErrorUpdateConfidenceImpossible(s Stream, i int) error {
+	return makePredictionErrorMaker(
+		"error.confidence.impossible",
+		"has an update with a confidence level below 0%% or above 100%%",
+	)(s, i)
+}
+
+// NewErrorUpdateOutOfOrder returns an error describing a prediction with an update dated before the prediction was made or before the update before it.
+func NewErrorUpdateOutOfOrder(s Stream, i int) error {
+	return makePredictionErrorMaker(
+		"error.update.out-of-order",
+		"has an update dated before the prediction was made or before the update before it",
+	)(s, i)
+}
+
+// NewErrorDuePassed returns an error describing a prediction whose due date has passed without it being resolved or excluded.
+func NewErrorDuePassed(s Stream, i int) error {
+	return makePredictionErrorMaker(
+		"warn.due.passed",
+		"is past its due date but has no “happened” value",
+	)(s, i)
+}
+
+// NewErrorOutcomesDontAddUp returns an error describing a multiple-choice prediction whose outcomes’ confidences don’t add up to 100%.
+func NewErrorOutcomesDontAddUp(s Stream, i int) error {
+	return makePredictionErrorMaker(
+		"error.outcomes.sum",
+		fmt.Sprintf("has outcomes whose confidences add up to %v%%%%, not 100%%%%", s.Predictions[i].Outcomes.Sum()),
+	)(s, i)
+}
+
+// NewErrorOutcomeConfidenceImpossible returns an error describing a multiple-choice prediction with an outcome that has a confidence level below 0% or above 100%.
+func NewErrorOutcomeConfidenceImpossible(s Stream, i int) error {
+	return makePredictionErrorMaker(
+		"error.confidence.impossible",
+		"has an outcome with a confidence level below 0%% or above 100%%",
+	)(s, i)
+}
+
+// NewErrorHappenedOutcomeUnknown returns an error describing a multiple-choice prediction whose “happened” value isn’t one of its outcomes.
+func NewErrorHappenedOutcomeUnknown(s Stream, i int) error {
+	return makePredictionErrorMaker(
+		"error.outcomes.unknown-happened",
+		fmt.Sprintf("says “%v” happened, which isn’t one of its outcomes", literal(s.Predictions[i].HappenedOutcome)),
+	)(s, i)
+}
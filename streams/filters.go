@@ -22,11 +22,11 @@ type Filter func(PredictionDocument) bool
 // Everything is a Filter that filters nothing out.
 func Everything(_ PredictionDocument) bool { return true }
 
-// MatchingTag returns a Filter that returns true if the prediction’s tag matches the given tag.
+// MatchingTag returns a Filter that returns true if the prediction has the given tag or a tag nested in it. A prediction tagged “politics/us” matches both “politics/us” and “politics”.
 func MatchingTag(tag string) Filter {
 	return func(d PredictionDocument) bool {
 		for _, predictionTag := range d.Tags {
-			if tag == predictionTag {
+			if IsTagWithin(predictionTag, tag) {
 				return true
 			}
 		}
@@ -48,11 +48,11 @@ func MatchingKey(key string) Filter {
 	}
 }
 
-// MatchingConfidence returns a Filter that returns true if the prediction’s confidence level matches (to within a fudge factor) the given confidence level.
+// MatchingConfidence returns a Filter that returns true if the prediction is a yes-or-no prediction whose confidence level matches (to within a fudge factor) the given confidence level.
 func MatchingConfidence(conf float64) Filter {
 	const ε = 0.0001
 	return func(d PredictionDocument) bool {
-		if d.Confidence != nil && math.Abs(*d.Confidence-conf) < ε {
+		if d.Confidence != nil && d.IsBinary() && math.Abs(*d.Confidence-conf) < ε {
 			return true
 		}
 
@@ -60,6 +60,11 @@ func MatchingConfidence(conf float64) Filter {
 	}
 }
 
+// Conditional is a Filter that returns true for conditional predictions.
+func Conditional(d PredictionDocument) bool {
+	return d.IsConditional()
+}
+
 // DocumentsMatching returns PredictionDocuments from streams that pass the given Filter.
 func DocumentsMatching(sts []Stream, f Filter) []PredictionDocument {
 	ret := make([]PredictionDocument, 0)
@@ -75,3 +80,23 @@ func DocumentsMatching(sts []Stream, f Filter) []PredictionDocument {
 
 	return ret
 }
+
+// Where returns copies of the given Streams with only the predictions that pass the given Filter. Streams left with no predictions are left out entirely. The originals are left alone.
+func Where(sts []Stream, f Filter) []Stream {
+	ret := make([]Stream, 0, len(sts))
+	for _, st := range sts {
+		pds := make([]PredictionDocument, 0, len(st.Predictions))
+		for _, p := range st.Predictions {
+			if f(p) {
+				pds = append(pds, p)
+			}
+		}
+		if len(pds) == 0 {
+			continue
+		}
+
+		st.Predictions = pds
+		ret = append(ret, st)
+	}
+	return ret
+}
@@ -0,0 +1,348 @@
+// © 2019 Nathan Galt
+//
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package streams
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// claimWidth is how long a “claim: …” line can get before the claim is wrapped.
+const claimWidth = 80
+
+// Format rewrites a stream’s text in the canonical style:
+//
+// - every document starts with “---”, and there are no blank lines between keys
+//
+// - keys are in the same order as PredictionDocument’s (or MetadataDocument’s) fields, with keys predictions doesn’t know about, like “private notes”, after them in the order they were in
+//
+// - YAML 1.1 booleans, like “yes” and “off”, are written as “true” and “false”, and a “happened” with no value is written as “null”
+//
+// - tags are written as flow sequences, like “[work, friends]”
+//
+// - claims too long to fit in 80 columns are wrapped as folded block scalars (“>-”), and claims that fit are written on one line
+//
+// - predictions written as flow mappings are written as block mappings
+//
+// Comments go with the keys they’re above or beside, and everything else, including block scalars, is left as it was. Empty documents and anything before the first “---” that isn’t YAML are left alone too.
+//
+// Format returns an error, and no text, if any document can’t be parsed, or if anything in the stream would read differently after formatting than it did before.
+func Format(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+
+	document := 0
+	for i, rd := range splitDocuments(b) {
+		n, err := rd.node()
+		if err == io.EOF {
+			if i > 0 {
+				document++
+			}
+			buf.Write(rd.text)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", rd.line, yamlMessage(err))
+		}
+
+		isMetadata := document == 0
+		document++
+
+		if len(n.Content) == 0 || n.Content[0].Kind != yaml.MappingNode || len(n.Content[0].Content) == 0 {
+			buf.Write(rd.text)
+			continue
+		}
+
+		t := reflect.TypeOf(PredictionDocument{})
+		if isMetadata {
+			t = reflect.TypeOf(MetadataDocument{})
+		}
+
+		if n.Content[0].Style&yaml.FlowStyle != 0 {
+			if rd, n, err = unflow(n); err != nil {
+				return nil, fmt.Errorf("line %d: %s", rd.line, err)
+			}
+		}
+		buf.Write(formatDocument(rd, n.Content[0], t))
+	}
+
+	out := buf.Bytes()
+	if err := sameContent(b, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// unflow turns a document that’s a flow mapping into one that’s a block mapping.
+func unflow(n *yaml.Node) (rawDocument, *yaml.Node, error) {
+	n.Content[0].Style &^= yaml.FlowStyle
+
+	var buf bytes.Buffer
+	buf.WriteString("---\n")
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(n); err != nil {
+		return rawDocument{}, nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return rawDocument{}, nil, err
+	}
+
+	rd := rawDocument{text: buf.Bytes(), line: 1}
+	n, err := rd.node()
+	return rd, n, err
+}
+
+// formatDocument returns the text of a document that’s a block mapping, which holds a document of type t, in the canonical style.
+func formatDocument(rd rawDocument, m *yaml.Node, t reflect.Type) []byte {
+	lines := bytes.SplitAfter(rd.text, []byte("\n"))
+	if last := lines[len(lines)-1]; len(last) > 0 && !bytes.HasSuffix(last, []byte("\n")) {
+		lines[len(lines)-1] = append(last, '\n')
+	}
+
+	rank := make(map[string]int)
+	for i, f := range documentFields(t) {
+		rank[f.key] = i
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("---\n")
+
+	// Comments above the first key stay at the top.
+	start := 0
+	if isDocumentMarker(lines[0]) {
+		start = 1
+	}
+	first := m.Content[0].Line - rd.line
+	writeComments(&buf, lines[start:first])
+
+	type segment struct {
+		text []byte
+		rank int
+	}
+	var segments []segment
+
+	indent := strings.Repeat(" ", m.Column-1)
+	categorical := mappingValue(m, "outcomes") != nil
+	previous := first
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		k, v := m.Content[i], m.Content[i+1]
+		at := k.Line - rd.line
+		end := valueEnd(lines, at)
+		if i+2 < len(m.Content) && m.Content[i+2].Line-rd.line < end {
+			end = m.Content[i+2].Line - rd.line
+		}
+
+		var seg bytes.Buffer
+		writeComments(&seg, lines[previous:at])
+		if v.LineComment == "" && v.Style&(yaml.FoldedStyle|yaml.LiteralStyle) != 0 {
+			v.LineComment = indicatorComment(lines[at])
+		}
+		if text := formatKey(indent, k.Value, v, categorical); text != "" {
+			seg.WriteString(text)
+		} else {
+			for _, line := range lines[at:end] {
+				seg.Write(line)
+			}
+		}
+
+		r, ok := rank[k.Value]
+		if !ok {
+			r = len(rank)
+		}
+		segments = append(segments, segment{seg.Bytes(), r})
+		previous = end
+	}
+
+	sort.SliceStable(segments, func(i, j int) bool { return segments[i].rank < segments[j].rank })
+	for _, seg := range segments {
+		buf.Write(seg.text)
+	}
+	writeComments(&buf, lines[previous:])
+
+	return buf.Bytes()
+}
+
+// indicatorComment returns the comment after a block scalar’s indicator, like the “# why” in “notes: | # why”, which the YAML parser drops.
+func indicatorComment(line []byte) string {
+	i := bytes.Index(line, []byte(" #"))
+	if i < 0 {
+		return ""
+	}
+	return strings.TrimSpace(string(line[i+1:]))
+}
+
+// writeComments writes every line that isn’t blank.
+func writeComments(buf *bytes.Buffer, lines [][]byte) {
+	for _, line := range lines {
+		if len(bytes.TrimSpace(line)) > 0 {
+			buf.Write(line)
+		}
+	}
+}
+
+// formatKey returns the canonical text of a key and its value, or the empty string if it should be left as it is.
+func formatKey(indent, key string, v *yaml.Node, categorical bool) string {
+	switch key {
+	case "claim":
+		if v.Kind == yaml.ScalarNode && v.Tag == "!!str" && !strings.Contains(v.Value, "\n") {
+			return formatClaim(indent, v)
+		}
+	case "happened", "hash":
+		if key == "happened" && categorical {
+			return ""
+		}
+		if b := canonicalBool(v); b != "" {
+			return keyLine(indent, key, b, v.LineComment)
+		}
+	case "tags":
+		if v.Kind == yaml.SequenceNode && v.Style&yaml.FlowStyle == 0 && !hasComments(v.Content) {
+			tags := make([]string, 0, len(v.Content))
+			for _, tag := range v.Content {
+				if tag.Kind != yaml.ScalarNode {
+					return ""
+				}
+				tags = append(tags, tag.Value)
+			}
+			value, _ := renderValue(tags)
+			return keyLine(indent, key, value, "")
+		}
+	}
+	return ""
+}
+
+// formatClaim returns a claim on one line, or, if it won’t fit in claimWidth columns, wrapped as a folded block scalar.
+//
+// Only claims that folding can’t change are wrapped: ones without tabs, runs of spaces, or spaces at either end.
+func formatClaim(indent string, v *yaml.Node) string {
+	s := v.Value
+	comment := ""
+	if v.LineComment != "" {
+		comment = " " + v.LineComment
+	}
+
+	foldable := !strings.Contains(s, "\t") && !strings.Contains(s, "  ") && strings.TrimSpace(s) == s
+	if !foldable || utf8.RuneCountInString(indent+"claim: "+s) <= claimWidth {
+		return keyLine(indent, "claim", yamlScalar(s, false), v.LineComment)
+	}
+
+	var buf strings.Builder
+	buf.WriteString(indent + "claim: >-" + comment + "\n")
+	lineIndent := indent + "  "
+	line := ""
+	for _, word := range strings.Split(s, " ") {
+		if line != "" && utf8.RuneCountInString(lineIndent+line+" "+word) > claimWidth {
+			buf.WriteString(lineIndent + line + "\n")
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	buf.WriteString(lineIndent + line + "\n")
+	return buf.String()
+}
+
+// canonicalBool returns “true”, “false”, or “null” for a scalar that’s a YAML 1.1 boolean or null, or the empty string for anything else.
+func canonicalBool(v *yaml.Node) string {
+	if v.Kind != yaml.ScalarNode || v.Style != 0 {
+		return ""
+	}
+
+	switch v.Value {
+	case "y", "Y", "yes", "Yes", "YES", "on", "On", "ON", "true", "True", "TRUE":
+		return "true"
+	case "n", "N", "no", "No", "NO", "off", "Off", "OFF", "false", "False", "FALSE":
+		return "false"
+	case "", "~", "null", "Null", "NULL":
+		return "null"
+	}
+	return ""
+}
+
+func hasComments(ns []*yaml.Node) bool {
+	for _, n := range ns {
+		if n.HeadComment != "" || n.LineComment != "" || n.FootComment != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// sameContent returns an error unless every document in after reads the same as the corresponding document in before: the same values for every key predictions knows about, and the same values for every key it doesn’t.
+func sameContent(before, after []byte) error {
+	bs, as := parseDocuments(splitDocuments(before)), parseDocuments(splitDocuments(after))
+	if len(bs) != len(as) {
+		return fmt.Errorf("formatting would turn %d documents into %d", len(bs), len(as))
+	}
+
+	for i := range bs {
+		t := reflect.TypeOf(PredictionDocument{})
+		if i == 0 {
+			t = reflect.TypeOf(MetadataDocument{})
+		}
+
+		if err := sameDocument(bs[i], as[i], t); err != nil {
+			return fmt.Errorf("line %d: formatting would change %s", bs[i].line, err)
+		}
+	}
+	return nil
+}
+
+func sameDocument(b, a parsedDocument, t reflect.Type) error {
+	if b.err != nil || a.err != nil {
+		return fmt.Errorf("whether it can be read")
+	}
+
+	bv, av := reflect.New(t), reflect.New(t)
+	if err := b.node.Decode(bv.Interface()); err != nil {
+		return err
+	}
+	if err := a.node.Decode(av.Interface()); err != nil {
+		return err
+	}
+
+	known := make(map[string]bool)
+	for _, f := range documentFields(t) {
+		known[f.key] = true
+		bs, _ := renderValue(f.value(bv.Elem()))
+		as, _ := renderValue(f.value(av.Elem()))
+		if bs != as {
+			return fmt.Errorf("“%s”", f.key)
+		}
+	}
+
+	var bm, am map[string]interface{}
+	_ = b.node.Decode(&bm)
+	_ = a.node.Decode(&am)
+	for k := range bm {
+		if !known[k] && !reflect.DeepEqual(bm[k], am[k]) {
+			return fmt.Errorf("“%s”", k)
+		}
+	}
+	for k := range am {
+		if _, ok := bm[k]; !ok {
+			return fmt.Errorf("“%s”", k)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+// © 2019 Nathan Galt
+//
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package streams
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// ShouldHash returns true if the receiver’s claim should be hashed before being published.
+//
+// A per-prediction salt implies that the prediction should be hashed, even without “hash: true”.
+func (d *PredictionDocument) ShouldHash() bool {
+	if d == nil {
+		return false
+	}
+
+	return d.Hash || d.Salt != ""
+}
+
+// EffectiveSalt returns the salt used when hashing the receiver’s claim.
+//
+// The per-prediction salt wins if there is one. If there isn’t, the whole-file salt from the parent stream’s metadata document is used. If neither exists, the claim is hashed unsalted and the empty string is returned.
+func (d *PredictionDocument) EffectiveSalt() string {
+	if d == nil {
+		return ""
+	}
+
+	if d.Salt != "" {
+		return d.Salt
+	}
+
+	if d.Parent != nil {
+		return d.Parent.Metadata.Salt
+	}
+
+	return ""
+}
+
+// ClaimToHash returns the receiver’s claim as it’s hashed: without the line break a claim written as a block scalar (“claim: >”) ends with.
+func (d *PredictionDocument) ClaimToHash() string {
+	if d == nil {
+		return ""
+	}
+
+	return strings.TrimRight(d.Claim, "\r\n")
+}
+
+// HashedClaim returns the hex-encoded SHA-256 hash of the receiver’s claim, as returned by ClaimToHash, with its effective salt appended.
+//
+// The result is the same as what you’d get from running `printf "%s" "$claim$salt" | shasum -a 256`.
+func (d *PredictionDocument) HashedClaim() string {
+	if d == nil {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(d.ClaimToHash() + d.EffectiveSalt()))
+	return hex.EncodeToString(sum[:])
+}
+
+// PublicClaim returns the hashed claim if the receiver should be hashed, and the claim itself otherwise.
+func (d *PredictionDocument) PublicClaim() string {
+	if d == nil {
+		return ""
+	}
+
+	if d.ShouldHash() {
+		return d.HashedClaim()
+	}
+
+	return d.Claim
+}
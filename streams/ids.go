@@ -0,0 +1,73 @@
+// © 2019 Nathan Galt
+//
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package streams
+
+import (
+	"fmt"
+)
+
+// IDsUsed returns every prediction ID used in the given Streams.
+func IDsUsed(sts []Stream) map[string]struct{} {
+	ret := make(map[string]struct{})
+	for _, s := range sts {
+		for _, pred := range s.Predictions {
+			if pred.ID != "" {
+				ret[pred.ID] = struct{}{}
+			}
+		}
+	}
+	return ret
+}
+
+// duplicateIDs returns a Problem for every prediction that has the same ID as a prediction before it, whether in the same stream or an earlier one.
+func duplicateIDs(sts []Stream) DecodeErrors {
+	var errs DecodeErrors
+	first := make(map[string]Position)
+	for _, s := range sts {
+		for i, pred := range s.Predictions {
+			if pred.ID == "" {
+				continue
+			}
+
+			if pos, ok := first[pred.ID]; ok {
+				errs = append(errs, &Problem{
+					ID:       "error.id.duplicate",
+					Position: pred.Position,
+					Index:    i,
+					Message:  fmt.Sprintf("ID “%s” is already used by the prediction at %s", pred.ID, pos),
+				})
+				continue
+			}
+			first[pred.ID] = pred.Position
+		}
+	}
+	return errs
+}
+
+// AddMissingIDs gives every prediction in a Stream that doesn’t have an ID one made by newID. It returns how many IDs were added. Write the Stream out with ToWriter or ToFile to save them.
+//
+// Predictions without claims, like the empty document at the end of a stream whose last line is “---”, are left alone.
+func AddMissingIDs(s *Stream, newID func() string) int {
+	added := 0
+	for i := range s.Predictions {
+		pred := &s.Predictions[i]
+		if pred.ID != "" || pred.Claim == "" {
+			continue
+		}
+		pred.ID = newID()
+		added++
+	}
+	return added
+}
@@ -0,0 +1,40 @@
+// © 2019 Nathan Galt
+//
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package streams
+
+// IsInterval returns true if the receiver is a numeric-range prediction, with a low and a high instead of a yes-or-no claim.
+//
+// A prediction with only one of the two is still an interval prediction, just a broken one.
+func (d *PredictionDocument) IsInterval() bool {
+	if d == nil {
+		return false
+	}
+
+	return d.Low != nil || d.High != nil
+}
+
+// IsBinary returns true if the receiver is an ordinary yes-or-no prediction.
+func (d *PredictionDocument) IsBinary() bool {
+	return !d.IsCategorical() && !d.IsInterval()
+}
+
+// Covered returns true if the receiver’s actual value is within its interval, inclusive. It returns false if the receiver has no actual value or isn’t a complete interval.
+func (d *PredictionDocument) Covered() bool {
+	if d == nil || d.Low == nil || d.High == nil || d.Actual == nil {
+		return false
+	}
+
+	return *d.Low <= *d.Actual && *d.Actual <= *d.High
+}
@@ -0,0 +1,178 @@
+// © 2019 Nathan Galt
+//
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package streams
+
+import (
+	"fmt"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// An Outcome is one of the options in a multiple-choice prediction, along with the confidence, out of 100, that it’s the one that happens.
+type Outcome struct {
+	Name       string
+	Confidence float64
+}
+
+// Outcomes holds the options of a multiple-choice prediction in the order they were written in.
+//
+// A plain map would lose that order, and people tend to list options in an order that means something to them.
+type Outcomes []Outcome
+
+// UnmarshalYAML decodes an “outcomes” mapping of option names to confidences.
+func (outs *Outcomes) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: outcomes must be a mapping of options to confidences", n.Line)
+	}
+
+	ret := make(Outcomes, 0, len(n.Content)/2)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		var o Outcome
+		if err := n.Content[i].Decode(&o.Name); err != nil {
+			return err
+		}
+		if err := n.Content[i+1].Decode(&o.Confidence); err != nil {
+			return err
+		}
+		ret = append(ret, o)
+	}
+
+	*outs = ret
+	return nil
+}
+
+// MarshalYAML encodes the receiver as a flow mapping of option names to confidences, like “{Alice: 40, Bob: 60}”.
+func (outs Outcomes) MarshalYAML() (interface{}, error) {
+	n := &yaml.Node{Kind: yaml.MappingNode, Style: yaml.FlowStyle}
+	for _, o := range outs {
+		n.Content = append(n.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: o.Name},
+			&yaml.Node{Kind: yaml.ScalarNode, Value: formatNumber(o.Confidence)})
+	}
+	return n, nil
+}
+
+// Sum returns the sum of the receiver’s confidences. It should be 100.
+func (outs Outcomes) Sum() float64 {
+	sum := 0.0
+	for _, o := range outs {
+		sum += o.Confidence
+	}
+	return sum
+}
+
+// Index returns the index of the outcome with the given name, or -1 if there isn’t one.
+func (outs Outcomes) Index(name string) int {
+	for i, o := range outs {
+		if o.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// IsFavorite returns true if the outcome with the given name exists and no other outcome was thought to be more likely.
+func (outs Outcomes) IsFavorite(name string) bool {
+	i := outs.Index(name)
+	if i < 0 {
+		return false
+	}
+
+	for _, o := range outs {
+		if o.Confidence > outs[i].Confidence {
+			return false
+		}
+	}
+	return true
+}
+
+// Favorite returns the outcome thought to be most likely. If several outcomes are tied, the first one wins.
+func (outs Outcomes) Favorite() Outcome {
+	var ret Outcome
+	for i, o := range outs {
+		if i == 0 || o.Confidence > ret.Confidence {
+			ret = o
+		}
+	}
+	return ret
+}
+
+// UnmarshalYAML decodes a prediction.
+//
+// It’s only needed because “happened” means two different things: in a yes-or-no prediction it’s a boolean, and in a multiple-choice prediction it’s the name of the outcome that happened.
+func (d *PredictionDocument) UnmarshalYAML(n *yaml.Node) error {
+	// plain has PredictionDocument’s fields but none of its methods, so decoding into it doesn’t end up back here.
+	type plain PredictionDocument
+	if err := n.Decode((*plain)(d)); err != nil {
+		return err
+	}
+
+	happened := mappingValue(n, "happened")
+	if happened == nil {
+		return nil
+	}
+
+	if d.IsCategorical() {
+		return happened.Decode(&d.HappenedOutcome)
+	}
+	return happened.Decode(&d.Happened)
+}
+
+// mappingValue returns the value node of the given key in a mapping node, or nil if there isn’t one.
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	if n.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// IsCategorical returns true if the receiver is a multiple-choice prediction, with outcomes instead of a single confidence.
+func (d *PredictionDocument) IsCategorical() bool {
+	if d == nil {
+		return false
+	}
+
+	return len(d.Outcomes) > 0
+}
+
+// IsResolved returns true if the receiver has a “happened” value, whatever kind of prediction it is.
+func (d *PredictionDocument) IsResolved() bool {
+	if d == nil {
+		return false
+	}
+
+	if d.IsCategorical() {
+		return d.HappenedOutcome != ""
+	}
+	if d.IsInterval() {
+		return d.Actual != nil
+	}
+	return d.Happened != nil
+}
+
+// IsOngoing returns true if the receiver is still waiting to be resolved: it has no “happened” value, no cause for exclusion, and no condition that failed.
+func (d *PredictionDocument) IsOngoing() bool {
+	if d == nil {
+		return false
+	}
+
+	return !d.IsResolved() && d.CauseForExclusion == "" && !d.ConditionFailed
+}
@@ -0,0 +1,63 @@
+// © 2019 Nathan Galt
+//
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package streams
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// A Period is a span of calendar time that predictions can be grouped into, like a month or a quarter.
+type Period int
+
+// Periods that predictions can be grouped into.
+const (
+	Month Period = iota
+	Quarter
+)
+
+// ParsePeriod turns “month” or “quarter” into a Period.
+func ParsePeriod(s string) (Period, error) {
+	switch strings.ToLower(s) {
+	case "month", "monthly":
+		return Month, nil
+	case "quarter", "quarterly":
+		return Quarter, nil
+	}
+	return Month, fmt.Errorf("unknown period “%s”; try “month” or “quarter”", s)
+}
+
+// Start returns the first instant of the period that t is in.
+func (p Period) Start(t time.Time) time.Time {
+	month := t.Month()
+	if p == Quarter {
+		month = (month-1)/3*3 + 1
+	}
+	return time.Date(t.Year(), month, 1, 0, 0, 0, 0, t.Location())
+}
+
+// Contains returns true if t is in the period that starts at start.
+func (p Period) Contains(start, t time.Time) bool {
+	return p.Start(t).Equal(start)
+}
+
+// Format describes the period that starts at start, like “March 2019” or “Q1 2019”.
+func (p Period) Format(start time.Time) string {
+	if p == Quarter {
+		return fmt.Sprintf("Q%d %d", (start.Month()-1)/3+1, start.Year())
+	}
+	return start.Format("January 2006")
+}
@@ -0,0 +1,397 @@
+// © 2019 Nathan Galt
+//
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package streams
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ParseFilter compiles a query, like `tag:work and not tag:oncall and confidence>=80 and scope:"in 2019"`, into a Filter.
+//
+// A query is one or more terms joined with “and”, “or”, and “not”, with parentheses for grouping. “not” binds tightest and “or” loosest. Each term is a field, an operator, and a value:
+//
+// - tag: the prediction has the tag, or a tag nested in it
+//
+// - title, scope: the prediction’s stream has that title or scope, ignoring case
+//
+// - claim: the claim contains the value, ignoring case
+//
+// - id, series: the prediction has exactly that ID or series
+//
+// - confidence: the prediction’s confidence, or its likeliest outcome’s, compared to a number
+//
+// - made, due, resolved: the prediction’s “made on”, “due”, or “resolved on” date compared to a date like 2019-06-30
+//
+// - happened: true or false for yes-or-no predictions, or the name of the outcome that happened for multiple-choice predictions
+//
+// - is: resolved, ongoing, excluded, conditional, yes-or-no, multiple-choice, numeric-range, hashed, or private
+//
+// Every field can be used with “:” (or “=”) and “!=”. Only confidence and dates can be used with “<”, “<=”, “>”, and “>=”. Values with spaces or parentheses in them need double quotes.
+func ParseFilter(query string) (Filter, error) {
+	p := queryParser{}
+	if err := p.lex(query); err != nil {
+		return nil, err
+	}
+
+	f, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != endToken {
+		return nil, fmt.Errorf("unexpected %s at position %d; did you leave out an “and” or an “or”?", t, t.pos+1)
+	}
+	return f, nil
+}
+
+type tokenKind int
+
+const (
+	endToken tokenKind = iota
+	wordToken
+	stringToken
+	operatorToken
+	openToken
+	closeToken
+)
+
+type queryToken struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// String describes the token for error messages, like “tag” or “the end of the query”.
+func (t queryToken) String() string {
+	if t.kind == endToken {
+		return "the end of the query"
+	}
+	return "“" + t.text + "”"
+}
+
+type queryParser struct {
+	tokens []queryToken
+	next   int
+}
+
+// lex splits a query into tokens.
+func (p *queryParser) lex(query string) error {
+	rs := []rune(query)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			p.tokens = append(p.tokens, queryToken{openToken, "(", i})
+			i++
+		case r == ')':
+			p.tokens = append(p.tokens, queryToken{closeToken, ")", i})
+			i++
+		case r == '"':
+			start := i
+			var sb strings.Builder
+			for i++; i < len(rs) && rs[i] != '"'; i++ {
+				if rs[i] == '\\' && i+1 < len(rs) {
+					i++
+				}
+				sb.WriteRune(rs[i])
+			}
+			if i == len(rs) {
+				return fmt.Errorf("quote at position %d is never closed", start+1)
+			}
+			i++
+			p.tokens = append(p.tokens, queryToken{stringToken, sb.String(), start})
+		case strings.ContainsRune(":=!<>", r):
+			start := i
+			i++
+			if i < len(rs) && rs[i] == '=' && r != ':' && r != '=' {
+				i++
+			}
+			op := string(rs[start:i])
+			if op == "!" {
+				return fmt.Errorf("“!” at position %d needs to be “!=”", start+1)
+			}
+			p.tokens = append(p.tokens, queryToken{operatorToken, op, start})
+		default:
+			start := i
+			for i < len(rs) && !unicode.IsSpace(rs[i]) && !strings.ContainsRune(`()":=!<>`, rs[i]) {
+				i++
+			}
+			p.tokens = append(p.tokens, queryToken{wordToken, string(rs[start:i]), start})
+		}
+	}
+	p.tokens = append(p.tokens, queryToken{endToken, "", len(rs)})
+	return nil
+}
+
+func (p *queryParser) peek() queryToken {
+	return p.tokens[p.next]
+}
+
+func (p *queryParser) take() queryToken {
+	t := p.tokens[p.next]
+	if t.kind != endToken {
+		p.next++
+	}
+	return t
+}
+
+// isKeyword returns true if the next token is the given keyword, in any case.
+func (p *queryParser) isKeyword(keyword string) bool {
+	t := p.peek()
+	return t.kind == wordToken && strings.EqualFold(t.text, keyword)
+}
+
+func (p *queryParser) or() (Filter, error) {
+	f, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isKeyword("or") {
+		p.take()
+		g, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		f = orFilter(f, g)
+	}
+	return f, nil
+}
+
+func (p *queryParser) and() (Filter, error) {
+	f, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isKeyword("and") {
+		p.take()
+		g, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		f = andFilter(f, g)
+	}
+	return f, nil
+}
+
+func (p *queryParser) not() (Filter, error) {
+	if !p.isKeyword("not") {
+		return p.primary()
+	}
+
+	p.take()
+	f, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	return notFilter(f), nil
+}
+
+func (p *queryParser) primary() (Filter, error) {
+	t := p.take()
+	switch t.kind {
+	case openToken:
+		f, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if c := p.take(); c.kind != closeToken {
+			return nil, fmt.Errorf("expected “)” at position %d, but got %s", c.pos+1, c)
+		}
+		return f, nil
+	case wordToken:
+		return p.term(t)
+	}
+	return nil, fmt.Errorf("expected a term like “tag:work” at position %d, but got %s", t.pos+1, t)
+}
+
+// term parses the rest of a term whose field has already been taken.
+func (p *queryParser) term(field queryToken) (Filter, error) {
+	op := p.take()
+	if op.kind != operatorToken {
+		return nil, fmt.Errorf("expected an operator like “:” after “%s” at position %d, but got %s", field.text, op.pos+1, op)
+	}
+
+	value := p.take()
+	if value.kind != wordToken && value.kind != stringToken {
+		return nil, fmt.Errorf("expected a value after “%s%s” at position %d, but got %s", field.text, op.text, value.pos+1, value)
+	}
+
+	f, err := termFilter(strings.ToLower(field.text), op.text, value.text)
+	if err != nil {
+		return nil, fmt.Errorf("in “%s%s%s” at position %d: %s", field.text, op.text, value.text, field.pos+1, err)
+	}
+	return f, nil
+}
+
+// termFilter returns a Filter for a single term.
+func termFilter(field, op, value string) (Filter, error) {
+	switch field {
+	case "confidence":
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("“%s” isn’t a number", value)
+		}
+		return compareFilter(op, func(d PredictionDocument) (int, bool) {
+			c := queryConfidence(d)
+			if c == nil {
+				return 0, false
+			}
+			return compareFloats(*c, n), true
+		})
+
+	case "made", "due", "resolved":
+		const layout = "2006-01-02"
+		if _, err := time.Parse(layout, value); err != nil {
+			return nil, fmt.Errorf("“%s” isn’t a date like 2019-06-30", value)
+		}
+		return compareFilter(op, func(d PredictionDocument) (int, bool) {
+			on := map[string]*time.Time{"made": d.MadeOn, "due": d.Due, "resolved": d.ResolvedOn}[field]
+			if on == nil {
+				return 0, false
+			}
+			// Dates in this layout sort the same way as strings as they do as dates.
+			return strings.Compare(on.Format(layout), value), true
+		})
+	}
+
+	var f Filter
+	switch field {
+	case "tag":
+		f = MatchingTag(value)
+	case "title":
+		f = func(d PredictionDocument) bool {
+			return d.Parent != nil && strings.EqualFold(d.Parent.Metadata.Title, value)
+		}
+	case "scope":
+		f = func(d PredictionDocument) bool {
+			return d.Parent != nil && strings.EqualFold(d.Parent.Metadata.Scope, value)
+		}
+	case "claim":
+		f = func(d PredictionDocument) bool {
+			return strings.Contains(strings.ToLower(d.Claim), strings.ToLower(value))
+		}
+	case "id":
+		f = func(d PredictionDocument) bool { return d.ID == value }
+	case "series":
+		f = func(d PredictionDocument) bool { return d.Series == value }
+	case "happened":
+		happened, isBool := map[string]bool{"true": true, "yes": true, "false": false, "no": false}[strings.ToLower(value)]
+		f = func(d PredictionDocument) bool {
+			if d.IsCategorical() {
+				return d.IsResolved() && strings.EqualFold(d.HappenedOutcome, value)
+			}
+			return isBool && d.IsBinary() && d.Happened != nil && *d.Happened == happened
+		}
+	case "is":
+		var ok bool
+		if f, ok = queryPredicates[strings.ToLower(value)]; !ok {
+			return nil, fmt.Errorf("unknown kind of prediction “%s”; try resolved, ongoing, excluded, conditional, yes-or-no, multiple-choice, numeric-range, hashed, or private", value)
+		}
+	default:
+		return nil, fmt.Errorf("unknown field “%s”; try tag, title, scope, claim, id, series, confidence, made, due, resolved, happened, or is", field)
+	}
+
+	switch op {
+	case ":", "=":
+		return f, nil
+	case "!=":
+		return notFilter(f), nil
+	}
+	return nil, fmt.Errorf("“%s” can only be used with confidence and dates", op)
+}
+
+// queryPredicates are the values an “is:” term can have.
+var queryPredicates = map[string]Filter{
+	"resolved": func(d PredictionDocument) bool { return d.IsResolved() },
+	"ongoing": func(d PredictionDocument) bool {
+		return !d.IsResolved() && d.CauseForExclusion == "" && !d.ConditionFailed
+	},
+	"excluded":        func(d PredictionDocument) bool { return d.CauseForExclusion != "" || d.ConditionFailed },
+	"conditional":     Conditional,
+	"yes-or-no":       func(d PredictionDocument) bool { return d.IsBinary() },
+	"binary":          func(d PredictionDocument) bool { return d.IsBinary() },
+	"multiple-choice": func(d PredictionDocument) bool { return d.IsCategorical() },
+	"categorical":     func(d PredictionDocument) bool { return d.IsCategorical() },
+	"numeric-range":   func(d PredictionDocument) bool { return d.IsInterval() },
+	"interval":        func(d PredictionDocument) bool { return d.IsInterval() },
+	"hashed":          func(d PredictionDocument) bool { return d.ShouldHash() },
+	"private":         func(d PredictionDocument) bool { return d.IsPrivate() },
+}
+
+// compareFilter returns a Filter that compares something about a prediction to a value. compare returns -1, 0, or 1 when the prediction’s is less than, equal to, or greater than the value, and false if the prediction doesn’t have one.
+func compareFilter(op string, compare func(PredictionDocument) (int, bool)) (Filter, error) {
+	var want func(int) bool
+	switch op {
+	case ":", "=":
+		want = func(c int) bool { return c == 0 }
+	case "!=":
+		want = func(c int) bool { return c != 0 }
+	case "<":
+		want = func(c int) bool { return c < 0 }
+	case "<=":
+		want = func(c int) bool { return c <= 0 }
+	case ">":
+		want = func(c int) bool { return c > 0 }
+	case ">=":
+		want = func(c int) bool { return c >= 0 }
+	default:
+		return nil, fmt.Errorf("unknown operator “%s”", op)
+	}
+
+	return func(d PredictionDocument) bool {
+		c, ok := compare(d)
+		return ok && want(c)
+	}, nil
+}
+
+// compareFloats returns -1, 0, or 1 when a is less than, equal to (to within a fudge factor), or greater than b.
+func compareFloats(a, b float64) int {
+	const ε = 0.0001
+	switch {
+	case a < b-ε:
+		return -1
+	case a > b+ε:
+		return 1
+	}
+	return 0
+}
+
+// queryConfidence returns the confidence a query compares against: the prediction’s confidence, or its likeliest outcome’s if it’s a multiple-choice prediction.
+func queryConfidence(d PredictionDocument) *float64 {
+	if d.IsCategorical() {
+		c := d.Outcomes.Favorite().Confidence
+		return &c
+	}
+	return d.Confidence
+}
+
+func andFilter(f, g Filter) Filter {
+	return func(d PredictionDocument) bool { return f(d) && g(d) }
+}
+
+func orFilter(f, g Filter) Filter {
+	return func(d PredictionDocument) bool { return f(d) || g(d) }
+}
+
+func notFilter(f Filter) Filter {
+	return func(d PredictionDocument) bool { return !f(d) }
+}
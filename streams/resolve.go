@@ -0,0 +1,58 @@
+// © 2019 Nathan Galt
+//
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package streams
+
+import (
+	"strings"
+	"time"
+)
+
+// A Resolution is how an ongoing prediction turned out. Set Happened for a yes-or-no prediction, HappenedOutcome for a multiple-choice prediction, Actual for a numeric-range prediction, or CauseForExclusion for a prediction of any kind that shouldn’t be scored.
+//
+// Notes are added after any notes the prediction already has. ResolvedOn is left alone if it’s nil.
+type Resolution struct {
+	Happened          *bool
+	HappenedOutcome   string
+	Actual            *float64
+	CauseForExclusion string
+	Notes             string
+	ResolvedOn        *time.Time
+}
+
+// Resolve records how the receiver turned out. Write its Stream out with ToWriter or ToFile to save it.
+func (d *PredictionDocument) Resolve(r Resolution) {
+	switch {
+	case r.Happened != nil:
+		d.Happened = r.Happened
+	case r.HappenedOutcome != "":
+		d.HappenedOutcome = r.HappenedOutcome
+	case r.Actual != nil:
+		d.Actual = r.Actual
+	}
+	if r.CauseForExclusion != "" {
+		d.CauseForExclusion = r.CauseForExclusion
+	}
+	if r.ResolvedOn != nil {
+		d.ResolvedOn = r.ResolvedOn
+	}
+
+	switch {
+	case r.Notes == "":
+	case d.Notes == "":
+		d.Notes = r.Notes
+	default:
+		d.Notes = strings.TrimRight(d.Notes, "\n") + "\n" + r.Notes + "\n"
+	}
+}
@@ -0,0 +1,56 @@
+// © 2019 Nathan Galt
+//
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package streams
+
+import (
+	"bytes"
+	"time"
+)
+
+// NewStreamFile returns the contents of a new stream file: a metadata document with the given title, scope, and salt, followed by an example prediction made on the given day with the given tags.
+//
+// Anything left blank is left out. The result passes every check in Validator.RunAll as long as the title or the scope isn’t blank.
+func NewStreamFile(title, scope, salt string, tags []string, madeOn time.Time) []byte {
+	var buf bytes.Buffer
+	scalar := func(key, value string) {
+		if value != "" {
+			buf.WriteString(keyLine("", key, yamlScalar(value, false), ""))
+		}
+	}
+
+	buf.WriteString("---\n")
+	scalar("title", title)
+	scalar("scope", scope)
+	if salt != "" {
+		buf.WriteString("# Claims of predictions with “hash: true” are salted with this before they’re hashed. Keep it secret until you reveal them.\n")
+		scalar("salt", salt)
+	}
+
+	buf.WriteString("---\n")
+	buf.WriteString("# This is an example. Replace it with your own predictions, separated by lines of three hyphens.\n")
+	confidence := 80.0
+	// Only the day goes in the file, not the time of day.
+	y, m, d := madeOn.Date()
+	madeOn = time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	// Nothing in a PredictionDocument like this one can fail to render.
+	_ = writeDocument(&buf, PredictionDocument{
+		Claim:      "I will make at least ten predictions in this file",
+		Confidence: &confidence,
+		Tags:       tags,
+		MadeOn:     &madeOn,
+	})
+
+	return buf.Bytes()
+}
@@ -0,0 +1,58 @@
+// © 2019 Nathan Galt
+//
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package streams
+
+import "sort"
+
+// SeriesIn returns the indexes of the predictions in each series in the given stream, keyed by series name.
+//
+// A series is a ladder of yes-or-no predictions, like “I will read at least one book”, “…at least two books”, and “…at least five books”, where each prediction claims more than the one before it. Indexes are in ladder order: by “series order” if every prediction in the series has one, and in file order otherwise.
+func SeriesIn(s Stream) map[string][]int {
+	ret := make(map[string][]int)
+	for i, pred := range s.Predictions {
+		if pred.Series == "" || !pred.IsBinary() {
+			continue
+		}
+		ret[pred.Series] = append(ret[pred.Series], i)
+	}
+
+	for _, is := range ret {
+		ordered := true
+		for _, i := range is {
+			if s.Predictions[i].SeriesOrder == nil {
+				ordered = false
+				break
+			}
+		}
+
+		if ordered {
+			sort.SliceStable(is, func(a, b int) bool {
+				return *s.Predictions[is[a]].SeriesOrder < *s.Predictions[is[b]].SeriesOrder
+			})
+		}
+	}
+
+	return ret
+}
+
+// seriesNames returns the keys of the map SeriesIn returns, sorted, so validators report problems in a predictable order.
+func seriesNames(series map[string][]int) []string {
+	ret := make([]string, 0, len(series))
+	for name := range series {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
@@ -20,11 +20,14 @@ package streams
 import (
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/xtgo/set"
-	yaml "gopkg.in/yaml.v2"
+	yaml "gopkg.in/yaml.v3"
 )
 
 // A ValidationError is returned when something about the stream isn’t right.
@@ -44,6 +47,48 @@ type Stream struct {
 	FromFilename string
 	Metadata     MetadataDocument
 	Predictions  []PredictionDocument
+
+	// raw is the stream’s text, split into documents, for ToWriter to write back. documents has the index in raw of every document that was read, starting with the metadata document.
+	raw       []rawDocument
+	documents []int
+}
+
+// A Position says where a document starts in a stream.
+type Position struct {
+	Filename string // may be empty
+	Document int    // the metadata document is document 0 and the first prediction is document 1
+	Line     int    // starts at 1
+	Column   int    // starts at 1
+}
+
+// String returns the position as “file.yaml:123:1”, or “123:1” if there’s no filename, or just “file.yaml” if there’s no line number.
+func (p Position) String() string {
+	if p.Line == 0 {
+		return p.Filename
+	}
+
+	s := fmt.Sprintf("%d:%d", p.Line, p.Column)
+	if p.Filename != "" {
+		s = p.Filename + ":" + s
+	}
+	return s
+}
+
+// positionOf finds where a document node’s content starts. Empty documents start where the document does.
+func positionOf(n *yaml.Node, filename string, document int) Position {
+	p := Position{
+		Filename: filename,
+		Document: document,
+		Line:     n.Line,
+		Column:   n.Column,
+	}
+
+	if len(n.Content) > 0 && n.Content[0].Kind == yaml.MappingNode {
+		p.Line = n.Content[0].Line
+		p.Column = n.Content[0].Column
+	}
+
+	return p
 }
 
 // A MetadataDocument contains information about the predictions in its Stream.
@@ -53,6 +98,14 @@ type MetadataDocument struct {
 	Salt  string
 	Notes string
 
+	// Private streams only have their scores shown in output meant for other people. None of their predictions are shown.
+	Private bool
+
+	// TagOrder lists the tags the stream’s author wants shown first, in the order they should be shown in.
+	TagOrder []string `yaml:"tag order"`
+
+	Position Position `yaml:"-"`
+
 	// These are here to detect when a user accidentally omits a metadata document in a stream.
 	MisplacedClaim      string `yaml:"claim"`
 	MisplacedConfidence string `yaml:"confidence"`
@@ -60,16 +113,46 @@ type MetadataDocument struct {
 
 // A PredictionDocument contains a claim, the claim’s confidence, and so on.
 type PredictionDocument struct {
+	ID                string
 	Claim             string
 	Confidence        *float64
 	Tags              []string
-	Happened          *bool
+	Happened          *bool  `yaml:"-"`
 	CauseForExclusion string `yaml:"cause for exclusion"`
 	Hash              bool
 	Salt              string
 	Notes             string
+	Series            string
+	SeriesOrder       *float64 `yaml:"series order"`
+
+	// Given is the condition a conditional prediction depends on. ConditionFailed is set when the prediction it depends on turned out the other way, which excludes the conditional prediction.
+	Given           *Condition
+	ConditionFailed bool `yaml:"-"`
+
+	// Outcomes and HappenedOutcome are only used in multiple-choice predictions, which have them instead of Confidence and Happened.
+	Outcomes        Outcomes
+	HappenedOutcome string `yaml:"-"`
+
+	// Low, High, and Actual are only used in numeric-range predictions, which have them instead of Happened. Confidence is how likely it is that Actual will be between Low and High.
+	Low    *float64
+	High   *float64
+	Actual *float64
+
+	MadeOn     *time.Time `yaml:"made on"`
+	Due        *time.Time
+	ResolvedOn *time.Time `yaml:"resolved on"`
+
+	// Updates are changes of confidence after the prediction was made, oldest first. Confidence stays the confidence the prediction was made with, unless WithForecast replaces it; if it does, InitialConfidence keeps the original.
+	Updates           []Update
+	InitialConfidence *float64 `yaml:"-"`
+
+	Position Position `yaml:"-"`
+	Parent   *Stream
+}
 
-	Parent *Stream
+// IsPrivate returns true if the receiver is in a stream whose metadata says it’s private.
+func (d *PredictionDocument) IsPrivate() bool {
+	return d != nil && d.Parent != nil && d.Parent.Metadata.Private
 }
 
 // ShouldExclude returns true if the receiver should be excluded from stats calculation.
@@ -78,6 +161,18 @@ func (d *PredictionDocument) ShouldExclude() bool {
 		return false
 	}
 
+	if d.ConditionFailed {
+		return true
+	}
+
+	if d.IsCategorical() {
+		return !d.IsResolved() || d.CauseForExclusion != ""
+	}
+
+	if d.IsInterval() {
+		return d.Confidence == nil || !d.IsResolved() || d.CauseForExclusion != ""
+	}
+
 	if d.Confidence == nil || d.Happened == nil || d.CauseForExclusion != "" {
 		return true
 	}
@@ -100,88 +195,171 @@ func (d *PredictionDocument) HasTag(tag string) bool {
 
 }
 
+// DecodeErrors holds every error found while reading one or more streams.
+//
+// Streams can still be used when reading them returns DecodeErrors. They just won’t have anything in them that couldn’t be read.
+type DecodeErrors []error
+
+func (es DecodeErrors) Error() string {
+	ss := make([]string, 0, len(es))
+	for _, err := range es {
+		ss = append(ss, err.Error())
+	}
+	return strings.Join(ss, "\n")
+}
+
+// yamlMessage makes an error from the YAML library fit on one line. Duplicate keys get a hint, since they were quietly allowed before.
+func yamlMessage(err error) string {
+	msg := strings.TrimPrefix(err.Error(), "yaml: ")
+	if te, ok := err.(*yaml.TypeError); ok {
+		msg = strings.Join(te.Errors, "; ")
+	}
+	if strings.Contains(msg, "already defined at line") {
+		msg += "; each key can only be given once per document"
+	}
+	return msg
+}
+
 func fromReaderWithFilename(r io.Reader, filename string) (Stream, error) {
-	dec := yaml.NewDecoder(r)
 	var s Stream
 	var md MetadataDocument
 	var pds []PredictionDocument
+	var errs DecodeErrors
 
 	s.FromFilename = filename
 
-	err := dec.Decode(&md)
+	b, err := ioutil.ReadAll(r)
 	if err != nil {
-		if err == io.EOF {
-			return Stream{}, NeitherTitleNorScopeInMetadataBlock
-		}
-		return Stream{}, errors.WithMessage(err, "error while decoding metadata document")
+		return Stream{}, errors.WithMessage(err, "error while reading stream")
+	}
+	s.raw = splitDocuments(b)
+	docs := parseDocuments(s.raw)
+	for _, doc := range docs {
+		s.documents = append(s.documents, doc.raw)
+	}
+	if len(docs) == 0 {
+		return Stream{}, NeitherTitleNorScopeInMetadataBlock
 	}
 
-	filenamePrefix := ""
-	if filename != "" {
-		filenamePrefix = filename + ": "
+	err = docs[0].err
+	if err == nil {
+		err = docs[0].node.Decode(&md)
+	}
+	if err != nil {
+		md = MetadataDocument{}
+	}
+	md.Position = docs[0].position(filename, 0)
+	if err != nil {
+		errs = append(errs, newMetadataProblem(md, "error.document.unreadable",
+			"couldn’t read the metadata document: %s", yamlMessage(err)))
 	}
 
 	if md.MisplacedClaim != "" {
-		return Stream{}, fmt.Errorf("%s[error.metadata.unexpected-claim]: claim of “%s” in first (metadata) document",
-			filenamePrefix, md.MisplacedClaim)
+		errs = append(errs, newMetadataProblem(md, "error.metadata.unexpected-claim",
+			"claim of “%s” in first (metadata) document", md.MisplacedClaim))
 	}
 
 	if md.MisplacedConfidence != "" {
-		return Stream{}, fmt.Errorf("%s[error.metadata.unexpected-confidence]: confidence of “%s” in first (metadata) document", filenamePrefix, md.MisplacedConfidence)
+		errs = append(errs, newMetadataProblem(md, "error.metadata.unexpected-confidence",
+			"confidence of “%s” in first (metadata) document", md.MisplacedConfidence))
 	}
 
 	s.Metadata = md
 
-	for {
+	for i, doc := range docs[1:] {
 		var pd PredictionDocument
-		err = dec.Decode(&pd)
+		pd.Position = doc.position(filename, i+1)
+
+		err := doc.err
+		if err == nil {
+			err = doc.node.Decode(&pd)
+		}
 		if err != nil {
-			break
+			errs = append(errs, &Problem{
+				ID:       "error.document.unreadable",
+				Position: pd.Position,
+				Index:    -1,
+				Message:  "couldn’t read this prediction: " + yamlMessage(err),
+			})
+			continue
 		}
+
 		pd.Parent = &s
 		pds = append(pds, pd)
 	}
-	if err != io.EOF {
-		if len(pds) == 0 {
-			return Stream{}, errors.WithMessagef(err, "error reading the first prediction")
-		}
-
-		knownGoodPrediction := pds[len(pds)-1]
-		return Stream{}, errors.WithMessagef(err,
-			"error reading the prediction after the one with the following claim: “%v”",
-			knownGoodPrediction.Claim,
-		)
-	}
 
 	s.Predictions = pds
 
+	if len(errs) > 0 {
+		return s, errs
+	}
 	return s, nil
 }
 
 // FromReader decodes into a Stream from an io.Reader.
+//
+// If some documents in the stream can’t be read, FromReader returns a Stream with everything that could be read and a DecodeErrors describing everything that couldn’t. Predictions that share an ID are reported in the DecodeErrors too.
 func FromReader(r io.Reader) (Stream, error) {
-	return fromReaderWithFilename(r, "")
+	s, err := fromReaderWithFilename(r, "")
+
+	sts := []Stream{s}
+	resolveConditions(sts)
+	if dups := duplicateIDs(sts); len(dups) > 0 {
+		switch e := err.(type) {
+		case nil:
+			err = dups
+		case DecodeErrors:
+			err = append(e, dups...)
+		}
+	}
+
+	return s, err
 }
 
 // FromFiles generates a slice of Stream from the filenames specified.
+//
+// Like FromReader, FromFiles keeps going when something can’t be read. It returns every Stream it could read, and, if anything went wrong, a DecodeErrors describing everything that did, even if that means a file that couldn’t be opened.
+//
+// FromFiles also checks that no two predictions in any of the files share an ID.
 func FromFiles(filenames []string) ([]Stream, error) {
 	streams := make([]Stream, 0, 1)
+	var errs DecodeErrors
 
 	for _, fn := range filenames {
 		f, err := os.Open(fn)
 		if err != nil {
-			return nil, errors.WithMessagef(err, "couldn’t open file named “%v”", fn)
+			errs = append(errs, &Problem{
+				ID:       "error.stream.unreadable",
+				Position: Position{Filename: fn},
+				Index:    -1,
+				Message:  fmt.Sprintf("couldn’t open file: %v", err),
+			})
+			continue
 		}
 		defer f.Close()
 
 		s, err := fromReaderWithFilename(f, fn)
-		if err != nil {
-			return nil, errors.WithMessagef(err, "couldn’t make stream from file “%v”", fn)
+		if des, ok := err.(DecodeErrors); ok {
+			errs = append(errs, des...)
+		} else if err != nil {
+			errs = append(errs, &Problem{
+				ID:       "error.stream.unreadable",
+				Position: Position{Filename: fn},
+				Index:    -1,
+				Message:  err.Error(),
+			})
+			continue
 		}
 
 		streams = append(streams, s)
 	}
 
+	resolveConditions(streams)
+	errs = append(errs, duplicateIDs(streams)...)
+
+	if len(errs) > 0 {
+		return streams, errs
+	}
 	return streams, nil
 }
 
@@ -191,7 +369,17 @@ func FromFiles(filenames []string) ([]Stream, error) {
 type ValidationFunction func(Stream) []error
 
 // A Validator contains data useful for ValidationFunctions.
-type Validator struct{}
+type Validator struct {
+	// Now is the time due dates are compared against. If it’s the zero time, the current time is used.
+	Now time.Time
+}
+
+func (sv *Validator) now() time.Time {
+	if sv.Now.IsZero() {
+		return time.Now()
+	}
+	return sv.Now
+}
 
 // RunValidationFunctions runs a bunch of validation functions on a stream.
 //
@@ -220,7 +408,17 @@ func (sv *Validator) RunAll(s Stream) []error {
 		sv.HasTitleOrScopeInMetadataBlock,
 		sv.AllPredictionsHaveClaims,
 		sv.AllPredictionsHaveConfidences,
+		sv.AllConfidencesBetweenZeroAndOneHundredInclusive,
 		sv.AllConfidencesSensible,
+		sv.AllOutcomesAddUp,
+		sv.AllHappenedOutcomesKnown,
+		sv.AllIntervalsComplete,
+		sv.AllSeriesMonotonic,
+		sv.AllSeriesOutcomesConsistent,
+		sv.AllConditionsKnown,
+		sv.AllDatesInOrder,
+		sv.AllUpdatesValid,
+		sv.NoDueDatesPassed,
 	)
 }
 
@@ -228,7 +426,8 @@ func (sv *Validator) RunAll(s Stream) []error {
 func (sv *Validator) HasTitleOrScopeInMetadataBlock(s Stream) []error {
 	errs := make([]error, 0)
 	if s.Metadata.Title == "" && s.Metadata.Scope == "" {
-		return append(errs, NeitherTitleNorScopeInMetadataBlock)
+		return append(errs, newMetadataProblem(s.Metadata, "error.metadata.no-title-or-scope",
+			"neither title nor scope in first (metadata) document"))
 	}
 	return errs
 }
@@ -250,7 +449,7 @@ func (sv *Validator) AllPredictionsHaveClaims(s Stream) []error {
 func (sv *Validator) AllPredictionsHaveConfidences(s Stream) []error {
 	errs := make([]error, 0)
 	for i, pred := range s.Predictions {
-		if pred.Confidence == nil {
+		if pred.Confidence == nil && !pred.IsCategorical() {
 			errs = append(errs, NewErrorConfidenceMissing(s, i))
 		}
 	}
@@ -262,7 +461,7 @@ func (sv *Validator) AllConfidencesBetweenZeroAndOneHundredInclusive(s Stream) [
 	errs := make([]error, 0)
 	for i, pred := range s.Predictions {
 		if pred.Confidence != nil &&
-			*(pred.Confidence) < 0.0 || *(pred.Confidence) > 100.0 {
+			(*(pred.Confidence) < 0.0 || *(pred.Confidence) > 100.0) {
 			errs = append(errs, NewErrorConfidenceImpossible(s, i))
 		}
 	}
@@ -284,6 +483,170 @@ func (sv *Validator) AllConfidencesSensible(s Stream) []error {
 	return errs
 }
 
+// outcomeSumTolerance is how far from 100 a multiple-choice prediction’s confidences can add up to before AllOutcomesAddUp complains. It leaves room for thirds written as 33.3.
+const outcomeSumTolerance = 0.5
+
+// AllOutcomesAddUp ensures that the confidences of every multiple-choice prediction’s outcomes are on [0, 100] and sum to 100.
+func (sv *Validator) AllOutcomesAddUp(s Stream) []error {
+	errs := make([]error, 0)
+	for i, pred := range s.Predictions {
+		if !pred.IsCategorical() {
+			continue
+		}
+
+		if sum := pred.Outcomes.Sum(); sum < 100-outcomeSumTolerance || sum > 100+outcomeSumTolerance {
+			errs = append(errs, NewErrorOutcomesDontAddUp(s, i))
+		}
+
+		for _, o := range pred.Outcomes {
+			if o.Confidence < 0 || o.Confidence > 100 {
+				errs = append(errs, NewErrorOutcomeConfidenceImpossible(s, i))
+				break
+			}
+		}
+	}
+	return errs
+}
+
+// AllHappenedOutcomesKnown ensures that every resolved multiple-choice prediction says that one of its own outcomes happened.
+func (sv *Validator) AllHappenedOutcomesKnown(s Stream) []error {
+	errs := make([]error, 0)
+	for i, pred := range s.Predictions {
+		if pred.IsCategorical() && pred.IsResolved() && pred.Outcomes.Index(pred.HappenedOutcome) < 0 {
+			errs = append(errs, NewErrorHappenedOutcomeUnknown(s, i))
+		}
+	}
+	return errs
+}
+
+// AllIntervalsComplete ensures that every numeric-range prediction has both a low and a high, that the low isn’t above the high, and that it isn’t 100% confident.
+func (sv *Validator) AllIntervalsComplete(s Stream) []error {
+	errs := make([]error, 0)
+	for i, pred := range s.Predictions {
+		if !pred.IsInterval() {
+			continue
+		}
+
+		if pred.Low == nil || pred.High == nil {
+			errs = append(errs, NewErrorIntervalIncomplete(s, i))
+		} else if *pred.Low > *pred.High {
+			errs = append(errs, NewErrorIntervalBackwards(s, i))
+		}
+
+		if pred.Confidence != nil && *pred.Confidence >= 100 {
+			errs = append(errs, NewErrorIntervalCertain(s, i))
+		}
+	}
+	return errs
+}
+
+// AllSeriesMonotonic ensures that no prediction in a series is more confident than one that comes before it. Claiming more can’t be more likely than claiming less.
+func (sv *Validator) AllSeriesMonotonic(s Stream) []error {
+	errs := make([]error, 0)
+	series := SeriesIn(s)
+	for _, name := range seriesNames(series) {
+		is := series[name]
+		for k := 1; k < len(is); k++ {
+			prev, cur := s.Predictions[is[k-1]], s.Predictions[is[k]]
+			if prev.Confidence != nil && cur.Confidence != nil && *cur.Confidence > *prev.Confidence {
+				errs = append(errs, NewErrorSeriesNonMonotonic(s, is[k], is[k-1]))
+			}
+		}
+	}
+	return errs
+}
+
+// AllSeriesOutcomesConsistent ensures that no prediction in a series happened when one that comes before it didn’t. If you didn’t read two books, you didn’t read five either.
+func (sv *Validator) AllSeriesOutcomesConsistent(s Stream) []error {
+	errs := make([]error, 0)
+	series := SeriesIn(s)
+	for _, name := range seriesNames(series) {
+		is := series[name]
+		for k, j := range is {
+			if s.Predictions[j].Happened == nil || !*s.Predictions[j].Happened {
+				continue
+			}
+
+			for _, earlier := range is[:k] {
+				if happened := s.Predictions[earlier].Happened; happened != nil && !*happened {
+					errs = append(errs, NewErrorSeriesInconsistentOutcomes(s, j, earlier))
+					break
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// AllConditionsKnown ensures that every conditional prediction is conditional on a yes-or-no prediction that was read along with it.
+func (sv *Validator) AllConditionsKnown(s Stream) []error {
+	errs := make([]error, 0)
+	for i, pred := range s.Predictions {
+		if pred.Given != nil && pred.Given.Target == nil {
+			errs = append(errs, NewErrorConditionUnknown(s, i))
+		}
+	}
+	return errs
+}
+
+// AllDatesInOrder ensures that no prediction is due, or was resolved, before it was made.
+func (sv *Validator) AllDatesInOrder(s Stream) []error {
+	errs := make([]error, 0)
+	for i, pred := range s.Predictions {
+		if pred.MadeOn == nil {
+			continue
+		}
+
+		if pred.Due != nil && pred.Due.Before(*pred.MadeOn) {
+			errs = append(errs, NewErrorDueBeforeMadeOn(s, i))
+		}
+		if pred.ResolvedOn != nil && pred.ResolvedOn.Before(*pred.MadeOn) {
+			errs = append(errs, NewErrorResolvedBeforeMadeOn(s, i))
+		}
+	}
+	return errs
+}
+
+// AllUpdatesValid ensures that every update has a confidence level, and that no update is dated before the prediction was made or before the update before it.
+func (sv *Validator) AllUpdatesValid(s Stream) []error {
+	errs := make([]error, 0)
+	for i, pred := range s.Predictions {
+		previous := pred.MadeOn
+		for _, u := range pred.Updates {
+			if u.Confidence == nil {
+				errs = append(errs, NewErrorUpdateConfidenceMissing(s, i))
+			} else if *u.Confidence < 0 || *u.Confidence > 100 {
+				errs = append(errs, NewErrorUpdateConfidenceImpossible(s, i))
+			}
+
+			if u.On == nil {
+				continue
+			}
+			if previous != nil && u.On.Before(*previous) {
+				errs = append(errs, NewErrorUpdateOutOfOrder(s, i))
+			}
+			previous = u.On
+		}
+	}
+	return errs
+}
+
+// NoDueDatesPassed ensures that no prediction that’s still ongoing has a due date before the validator’s notion of now.
+func (sv *Validator) NoDueDatesPassed(s Stream) []error {
+	errs := make([]error, 0)
+	now := sv.now()
+	for i, pred := range s.Predictions {
+		if pred.Due == nil || pred.IsResolved() || pred.CauseForExclusion != "" || pred.ConditionFailed {
+			continue
+		}
+
+		if pred.Due.Before(now) {
+			errs = append(errs, NewErrorDuePassed(s, i))
+		}
+	}
+	return errs
+}
+
 // other stuff
 
 func deduplicateStrings(ss []string) []string {
@@ -313,6 +676,82 @@ func TagsUsed(sts []Stream) []string {
 	return deduplicateStrings(ret)
 }
 
+// TagSeparator separates the levels of a nested tag, like “politics/us”.
+const TagSeparator = "/"
+
+// ParentTag returns the tag the given tag is nested in, like “politics” for “politics/us”, or "" if it isn’t nested in one.
+func ParentTag(tag string) string {
+	i := strings.LastIndex(tag, TagSeparator)
+	if i <= 0 {
+		return ""
+	}
+	return tag[:i]
+}
+
+// IsTagWithin returns true if the given tag is the same as ancestor or is nested in it, however deeply.
+func IsTagWithin(tag, ancestor string) bool {
+	return tag == ancestor || strings.HasPrefix(tag, ancestor+TagSeparator)
+}
+
+// TagsWithAncestors returns a list of all tags used in the given streams, along with every tag they’re nested in, whether or not any prediction has it. Each tag comes before the first tag nested in it.
+func TagsWithAncestors(sts []Stream) []string {
+	ret := make([]string, 0)
+	for _, tag := range TagsUsed(sts) {
+		ancestors := make([]string, 0)
+		for parent := ParentTag(tag); parent != ""; parent = ParentTag(parent) {
+			ancestors = append([]string{parent}, ancestors...)
+		}
+		ret = append(ret, ancestors...)
+		ret = append(ret, tag)
+	}
+	return deduplicateStrings(ret)
+}
+
+// TagOrder merges the “tag order” lists of the given streams into one.
+//
+// Each tag goes right after the tag listed before it in the same list, so every list’s order is kept. When two lists disagree about which of two tags comes first, the stream that comes first wins.
+func TagOrder(sts []Stream) []string {
+	ret := make([]string, 0)
+	index := func(tag string) int {
+		for i, t := range ret {
+			if t == tag {
+				return i
+			}
+		}
+		return -1
+	}
+
+	for _, s := range sts {
+		after := -1
+		for i, tag := range s.Metadata.TagOrder {
+			if j := index(tag); j >= 0 {
+				after = j
+				continue
+			}
+
+			at := len(ret)
+			if after >= 0 {
+				at = after + 1
+			} else {
+				// Nothing before it in this list has been placed yet, so it goes before the first thing after it that has been.
+				for _, later := range s.Metadata.TagOrder[i+1:] {
+					if j := index(later); j >= 0 {
+						at = j
+						break
+					}
+				}
+			}
+
+			ret = append(ret, "")
+			copy(ret[at+1:], ret[at:])
+			ret[at] = tag
+			after = at
+		}
+	}
+
+	return ret
+}
+
 // KeysUsed returns a list of all keys used in the given Streams.
 //
 // A “key”, here, is the title and scope of a given stream, with a space in between.
@@ -330,7 +769,7 @@ func ConfidencesUsed(sts []Stream) []float64 {
 	ret := make([]float64, 0)
 	for _, s := range sts {
 		for _, pred := range s.Predictions {
-			if !pred.ShouldExclude() {
+			if !pred.ShouldExclude() && pred.IsBinary() {
 				ret = append(ret, *pred.Confidence)
 			}
 		}
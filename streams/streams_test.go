@@ -15,9 +15,13 @@
 package streams
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io/ioutil"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -148,10 +152,10 @@ func TestMissingClaimsAndConfidences(t *testing.T) {
 	)
 
 	expecteds := []string{
-		"[error.confidence.missing]: first prediction, with claim “I will eat a steak”, has no confidence level specified",
-		"[error.confidence.missing]: prediction with claim “I will eat a dinner salad” has no confidence level specified",
-		"[error.confidence.missing]: prediction after prediction with claim “I will eat a dinner salad” has no confidence level specified",
-		"[error.confidence.missing]: prediction exists that has no confidence level specified; neither it nor its predecessor have a claim",
+		"5:1: [error.confidence.missing] first prediction, with claim “I will eat a steak”, has no confidence level specified",
+		"10:1: [error.confidence.missing] prediction with claim “I will eat a dinner salad” has no confidence level specified",
+		"14:1: [error.confidence.missing] prediction after prediction with claim “I will eat a dinner salad” has no confidence level specified",
+		"15:1: [error.confidence.missing] prediction exists that has no confidence level specified; neither it nor its predecessor have a claim",
 	}
 
 	AssertErrorsMatch(t, expecteds, errs)
@@ -175,6 +179,8 @@ confidence: 30
 ---
 claims: [I will like hoppy beer, I will like lamb]
 confidence: 20
+---
+# YAML doesn’t allow duplicate keys in a mapping, so this needs to be its own document
 claims: [I like water, I like food]
 confidence: 32
 `
@@ -185,9 +191,10 @@ func TestMissingClaims(t *testing.T) {
 	errs := sv.RunAll(s)
 
 	expecteds := []string{
-		"[error.claim.missing]: first prediction has no claim",
-		"[error.claim.missing]: claim after “I will like red meat” has no claim",
-		"[error.claim.missing]: prediction exists that has no claim, and neither does the one before it",
+		"8:1: [error.claim.missing] first prediction has no claim",
+		"14:1: [error.claim.missing] claim after “I will like red meat” has no claim",
+		"17:1: [error.claim.missing] prediction exists that has no claim, and neither does the one before it",
+		"21:1: [error.claim.missing] prediction exists that has no claim, and neither does the one before it",
 	}
 
 	AssertErrorsMatch(t, expecteds, errs)
@@ -218,10 +225,10 @@ func TestQuestionableConfidences(t *testing.T) {
 	)
 
 	expecteds := []string{
-		"[error.confidence.impossible]: first prediction, with claim “green is spiky”, has a confidence level below 0% or above 100%",
-		"[error.confidence.impossible]: prediction with claim “I will marry my middle-school crush” has a confidence level below 0% or above 100%",
-		"[warn.confidence.zero]: prediction with claim “my left arm will turn into a tentacle” has a confidence level of zero",
-		"[warn.confidence.unity]: prediction with claim “the sun will rise tomorrow” has a confidence level of one",
+		"4:1: [error.confidence.impossible] first prediction, with claim “green is spiky”, has a confidence level below 0% or above 100%",
+		"13:1: [error.confidence.impossible] prediction with claim “I will marry my middle-school crush” has a confidence level below 0% or above 100%",
+		"7:1: [warn.confidence.zero] prediction with claim “my left arm will turn into a tentacle” has a confidence level of zero",
+		"10:1: [warn.confidence.unity] prediction with claim “the sun will rise tomorrow” has a confidence level of one",
 	}
 
 	AssertErrorsMatch(t, expecteds, errs)
@@ -238,7 +245,7 @@ confidence: 80%
 func TestConfidenceWithPercentageSigns(t *testing.T) {
 	_, err := FromReader(strings.NewReader(confidenceWithPercentageSigns))
 	assert.EqualError(t, err,
-		"error reading the first prediction: yaml: unmarshal errors:\n  line 5: cannot unmarshal !!str `80%` into float64")
+		"4:1: [error.document.unreadable] couldn’t read this prediction: line 5: cannot unmarshal !!str `80%` into float64")
 }
 
 const confidenceOfMaybe = `
@@ -251,7 +258,7 @@ confidence: maybe
 func TestConfidenceNotAtAllANumber(t *testing.T) {
 	_, err := FromReader(strings.NewReader(confidenceOfMaybe))
 	assert.EqualError(t, err,
-		"error reading the first prediction: yaml: unmarshal errors:\n  line 5: cannot unmarshal !!str `maybe` into float64")
+		"4:1: [error.document.unreadable] couldn’t read this prediction: line 5: cannot unmarshal !!str `maybe` into float64")
 }
 
 const claimAndConfidenceInMetadata = `
@@ -262,7 +269,8 @@ confidence: 50
 func TestClaimInMetadata(t *testing.T) {
 	_, err := FromReader(strings.NewReader(claimAndConfidenceInMetadata))
 	assert.EqualError(t, err,
-		"[error.metadata.unexpected-claim]: claim of “it will rain tomorrow” in first (metadata) document")
+		"2:1: [error.metadata.unexpected-claim] claim of “it will rain tomorrow” in first (metadata) document\n"+
+			"2:1: [error.metadata.unexpected-confidence] confidence of “50” in first (metadata) document")
 }
 
 const confidenceInMetadata = `
@@ -273,6 +281,975 @@ claim: I will dislike 85% dark chocolate`
 func TestConfidenceInMetadata(t *testing.T) {
 	_, err := FromReader(strings.NewReader(confidenceInMetadata))
 	assert.EqualError(t, err,
-		"[error.metadata.unexpected-confidence]: confidence of “20” in first (metadata) document")
+		"2:1: [error.metadata.unexpected-confidence] confidence of “20” in first (metadata) document")
+
+}
+
+const saltedAndHashed = `---
+title: 'Predictions: for 2019'
+scope: for 2019
+salt: kkjskvjsdwolvkjsjv
+---
+claim: Dennis will not change jobs
+confidence: 90
+hash: yes
+---
+claim: I will not buy any socks
+confidence: 80
+salt: a-salt-of-its-own
+---
+claim: I will finish my book
+confidence: 60
+`
+
+func TestHashedClaims(t *testing.T) {
+	s := mustStreamFromString(t, saltedAndHashed)
+
+	dennis := s.Predictions[0]
+	assert.True(t, dennis.ShouldHash())
+	assert.Equal(t, "kkjskvjsdwolvkjsjv", dennis.EffectiveSalt())
+	assert.Equal(t, "06b940c01111111849d11d0b90726f24a95e0ac2c5817ad1a49a0f298561adfb", dennis.PublicClaim())
+
+	socks := s.Predictions[1]
+	assert.True(t, socks.ShouldHash(), "a per-prediction salt implies hashing")
+	assert.Equal(t, "a-salt-of-its-own", socks.EffectiveSalt())
+
+	book := s.Predictions[2]
+	assert.False(t, book.ShouldHash())
+	assert.Equal(t, "I will finish my book", book.PublicClaim())
+
+	folded := mustStreamFromString(t, `---
+title: Folded
+salt: pepper
+---
+claim: >
+  I will learn
+  to juggle
+hash: true
+`).Predictions[0]
+	sum := sha256.Sum256([]byte("I will learn to jugglepepper"))
+	assert.Equal(t, "I will learn to juggle", folded.ClaimToHash())
+	assert.Equal(t, hex.EncodeToString(sum[:]), folded.HashedClaim(), "a block scalar’s trailing line break shouldn’t be hashed")
+}
+
+const datedPredictions = `---
+title: Dated predictions
+scope: in 2019
+---
+claim: I will renew my passport
+confidence: 80
+made on: 2019-01-02
+due: 2019-06-30
+---
+claim: I will see a total solar eclipse
+confidence: 10
+made on: 2019-01-02
+due: 2019-12-31
+---
+claim: I will plant a tree
+confidence: 60
+made on: 2019-03-01
+due: 2019-02-01
+resolved on: 2019-02-15
+happened: true
+`
+
+func TestDates(t *testing.T) {
+	s := mustStreamFromString(t, datedPredictions)
+
+	if assert.NotNil(t, s.Predictions[0].MadeOn) {
+		assert.Equal(t, "2019-01-02", s.Predictions[0].MadeOn.Format("2006-01-02"))
+	}
+
+	sv := Validator{Now: time.Date(2019, time.July, 4, 0, 0, 0, 0, time.UTC)}
+	errs := sv.RunValidationFunctions(s,
+		sv.AllDatesInOrder,
+		sv.NoDueDatesPassed,
+	)
+
+	expecteds := []string{
+		"15:1: [error.due.before-made-on] prediction with claim “I will plant a tree” is due before it was made",
+		"15:1: [error.resolved-on.before-made-on] prediction with claim “I will plant a tree” was resolved before it was made",
+		"5:1: [warn.due.passed] first prediction, with claim “I will renew my passport”, is past its due date but has no “happened” value",
+	}
+
+	AssertErrorsMatch(t, expecteds, errs)
+}
+
+func TestPeriods(t *testing.T) {
+	d := time.Date(2019, time.August, 17, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, "August 2019", Month.Format(Month.Start(d)))
+	assert.Equal(t, "Q3 2019", Quarter.Format(Quarter.Start(d)))
+	assert.True(t, Quarter.Contains(time.Date(2019, time.July, 1, 0, 0, 0, 0, time.UTC), d))
+	assert.False(t, Month.Contains(time.Date(2019, time.July, 1, 0, 0, 0, 0, time.UTC), d))
+}
+
+const untitledQuestionableConfidences = `---
+notes: no title, no scope
+---
+claim: my left arm will turn into a tentacle
+confidence: 0
+---
+claim: the sun will rise tomorrow
+confidence: 100
+`
+
+func TestProblemSeverities(t *testing.T) {
+	s := mustStreamFromString(t, untitledQuestionableConfidences)
+	var sv Validator
+	errs := sv.RunAll(s)
+
+	severities := make(map[string]Severity)
+	for _, err := range errs {
+		p, ok := err.(*Problem)
+		if assert.True(t, ok, "validators should return Problems") {
+			severities[p.ID] = SeverityOf(err)
+		}
+	}
+
+	assert.Len(t, severities, 3)
+	assert.Equal(t, Error, severities["error.metadata.no-title-or-scope"])
+	assert.Equal(t, Warning, severities["warn.confidence.zero"])
+	assert.Equal(t, Warning, severities["warn.confidence.unity"])
+	assert.Equal(t, Error, SeverityOf(NeitherTitleNorScopeInMetadataBlock))
+}
+
+func TestPositions(t *testing.T) {
+	s, err := fromReaderWithFilename(strings.NewReader(missingClaimsAndConfidences), "food.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, Position{Filename: "food.yaml", Document: 0, Line: 2, Column: 1}, s.Metadata.Position)
+	assert.Equal(t, Position{Filename: "food.yaml", Document: 1, Line: 5, Column: 1}, s.Predictions[0].Position)
+	assert.Equal(t, Position{Filename: "food.yaml", Document: 5, Line: 15, Column: 1}, s.Predictions[4].Position, "empty documents start at their “---”")
+
+	var sv Validator
+	errs := sv.RunValidationFunctions(s, sv.AllPredictionsHaveClaims)
+	if assert.NotEmpty(t, errs) {
+		AssertEqualsError(t, "food.yaml:14:1: [error.claim.missing] claim after “I will eat a dinner salad” has no claim", errs[0])
+	}
+}
+
+const oneTypoAmongMany = `---
+title: Typos happen
+---
+claim: I will learn to juggle
+confidence: 60
+---
+claim: I will learn to unicycle
+confidence: sixty
+---
+claim: [I will learn to
+confidence: 30
+---
+claim: I will learn to juggle while riding a unicycle
+confidence: 5
+`
+
+func TestAllDecodeErrorsReported(t *testing.T) {
+	s, err := FromReader(strings.NewReader(oneTypoAmongMany))
+
+	des, ok := err.(DecodeErrors)
+	if !assert.True(t, ok, "expected DecodeErrors, got %#v", err) {
+		return
+	}
+
+	AssertErrorsMatch(t, []string{
+		"7:1: [error.document.unreadable] couldn’t read this prediction: line 8: cannot unmarshal !!str `sixty` into float64",
+		"9:1: [error.document.unreadable] couldn’t read this prediction: line 9: did not find expected ',' or ']'",
+	}, des)
+
+	if assert.Len(t, s.Predictions, 2, "the good predictions should still be there") {
+		assert.Equal(t, "I will learn to juggle", s.Predictions[0].Claim)
+		assert.Equal(t, "I will learn to juggle while riding a unicycle", s.Predictions[1].Claim)
+		assert.Equal(t, 4, s.Predictions[1].Position.Document)
+	}
+}
+
+func TestDuplicateKeysReported(t *testing.T) {
+	_, err := FromReader(strings.NewReader(`---
+title: Twice
+---
+claim: I will learn to juggle
+confidence: 50
+confidence: 60
+`))
+
+	des, ok := err.(DecodeErrors)
+	if !assert.True(t, ok, "expected DecodeErrors, got %#v", err) {
+		return
+	}
+
+	AssertErrorsMatch(t, []string{
+		"4:1: [error.document.unreadable] couldn’t read this prediction: line 6: mapping key \"confidence\" already defined at line 5; each key can only be given once per document",
+	}, des)
+}
+
+const multipleChoice = `---
+title: Who wins?
+---
+claim: The 2020 Democratic nominee will be
+outcomes:
+  Biden: 40
+  Sanders: 30
+  Warren: 20
+  someone else: 10
+happened: Biden
+---
+claim: The next Bond will be
+outcomes:
+  Elba: 50
+  Hardy: 30
+happened: Fassbender
+---
+claim: I will finish reading the Bond books
+confidence: 60
+happened: yes
+---
+claim: The committee will be chaired by
+outcomes: {Kim: 150, Lee: -50}
+`
+
+func TestMultipleChoice(t *testing.T) {
+	s := mustStreamFromString(t, multipleChoice)
+
+	nominee := s.Predictions[0]
+	assert.True(t, nominee.IsCategorical())
+	assert.Equal(t, Outcomes{{"Biden", 40}, {"Sanders", 30}, {"Warren", 20}, {"someone else", 10}}, nominee.Outcomes, "outcomes should stay in the order they were written in")
+	assert.Equal(t, "Biden", nominee.HappenedOutcome)
+	assert.Nil(t, nominee.Happened)
+	assert.True(t, nominee.Outcomes.IsFavorite("Biden"))
+	assert.False(t, nominee.Outcomes.IsFavorite("Warren"))
+
+	books := s.Predictions[2]
+	assert.False(t, books.IsCategorical())
+	if assert.NotNil(t, books.Happened) {
+		assert.True(t, *books.Happened)
+	}
+
+	var sv Validator
+	AssertErrorsMatch(t, []string{
+		"12:1: [error.outcomes.sum] prediction with claim “The next Bond will be” has outcomes whose confidences add up to 80%, not 100%",
+		"22:1: [error.confidence.impossible] prediction with claim “The committee will be chaired by” has an outcome with a confidence level below 0% or above 100%",
+		"12:1: [error.outcomes.unknown-happened] prediction with claim “The next Bond will be” says “Fassbender” happened, which isn’t one of its outcomes",
+	}, sv.RunAll(s))
+}
+
+const numericRanges = `---
+title: How much?
+---
+claim: Revenue in millions
+low: 2
+high: 3
+confidence: 80
+actual: 2.4
+---
+claim: Headcount
+low: 40
+confidence: 90
+---
+claim: Books read
+low: 20
+high: 12
+confidence: 50
+---
+claim: I will hire someone
+confidence: 70
+happened: yes
+---
+claim: Commute in minutes
+low: 20
+high: 40
+confidence: 100
+actual: 55
+`
+
+func TestNumericRanges(t *testing.T) {
+	s := mustStreamFromString(t, numericRanges)
+
+	revenue := s.Predictions[0]
+	assert.True(t, revenue.IsInterval())
+	assert.True(t, revenue.IsResolved())
+	assert.True(t, revenue.Covered())
+	assert.False(t, revenue.ShouldExclude())
+	assert.True(t, s.Predictions[3].IsBinary())
+
+	assert.Equal(t, []float64{70}, ConfidencesUsed([]Stream{s}), "interval confidences shouldn’t be mixed in with yes-or-no ones")
+	assert.Len(t, DocumentsMatching([]Stream{s}, MatchingConfidence(80)), 0, "interval confidences shouldn’t match yes-or-no ones")
+	assert.Len(t, DocumentsMatching([]Stream{s}, MatchingConfidence(70)), 1)
+
+	var sv Validator
+	AssertErrorsMatch(t, []string{
+		"23:1: [warn.confidence.unity] prediction with claim “Commute in minutes” has a confidence level of one",
+		"10:1: [error.interval.incomplete] prediction with claim “Headcount” has only one of “low” and “high”",
+		"14:1: [error.interval.backwards] prediction with claim “Books read” has a low above its high",
+		"23:1: [error.interval.certain] prediction with claim “Commute in minutes” is a numeric range with a confidence level of 100",
+	}, sv.RunAll(s))
+}
+
+const bookLadder = `---
+title: Books
+---
+claim: I will read at least five books
+confidence: 70
+happened: true
+series: books
+series order: 5
+---
+claim: I will read at least one book
+confidence: 95
+happened: true
+series: books
+series order: 1
+---
+claim: I will read at least two books
+confidence: 97
+happened: false
+series: books
+series order: 2
+`
+
+func TestSeries(t *testing.T) {
+	s := mustStreamFromString(t, bookLadder)
+
+	assert.Equal(t, map[string][]int{"books": {1, 2, 0}}, SeriesIn(s), "series should be sorted by series order")
+
+	var sv Validator
+	AssertErrorsMatch(t, []string{
+		"16:1: [warn.series.non-monotonic] prediction with claim “I will read at least two books” is more confident than “I will read at least one book”, which comes before it in series “books”",
+		"4:1: [error.series.inconsistent-outcomes] first prediction, with claim “I will read at least five books”, happened, but “I will read at least two books”, which comes before it in series “books”, didn’t",
+	}, sv.RunAll(s))
+}
+
+const jobConditions = `---
+title: Jobs
+---
+id: new-job
+claim: I will get a new job
+confidence: 40
+happened: false
+---
+claim: I will move to Portland
+confidence: 60
+given: new-job
+---
+claim: I will take a long vacation
+confidence: 70
+given:
+  id: new-job
+  happened: false
+---
+claim: I will buy a boat
+confidence: 10
+given: boat-money
+`
+
+func TestConditions(t *testing.T) {
+	s, err := FromReader(strings.NewReader(jobConditions))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	move, vacation, boat := s.Predictions[1], s.Predictions[2], s.Predictions[3]
+
+	assert.Equal(t, Condition{ID: "new-job", Happened: true, Target: &s.Predictions[0]}, *move.Given)
+	assert.True(t, move.ConditionFailed, "a prediction given something that didn’t happen should be excluded")
+	assert.True(t, move.ShouldExclude())
+
+	assert.False(t, vacation.Given.Happened)
+	assert.False(t, vacation.ConditionFailed)
+
+	assert.Nil(t, boat.Given.Target)
+	assert.False(t, boat.ConditionFailed, "a prediction given something unknown shouldn’t be excluded")
+
+	var sv Validator
+	AssertErrorsMatch(t, []string{
+		"19:1: [warn.given.unknown] prediction with claim “I will buy a boat” is given “boat-money”, but no yes-or-no prediction has that ID",
+	}, sv.RunAll(s))
+}
+
+func TestConditionsAcrossStreams(t *testing.T) {
+	money := mustStreamFromString(t, `---
+title: Money
+---
+id: boat-money
+claim: I will have boat money
+confidence: 5
+happened: false
+`)
+	jobs := mustStreamFromString(t, jobConditions)
+
+	sts := []Stream{money, jobs}
+	resolveConditions(sts)
+
+	assert.Equal(t, &sts[0].Predictions[0], sts[1].Predictions[3].Given.Target)
+	assert.True(t, sts[1].Predictions[3].ConditionFailed)
+}
+
+func TestDuplicateIDs(t *testing.T) {
+	_, err := FromReader(strings.NewReader(`---
+title: Twins
+---
+id: twin
+claim: I will meet my twin
+confidence: 1
+---
+id: twin
+claim: I will meet my evil twin
+confidence: 1
+`))
+
+	des, ok := err.(DecodeErrors)
+	if assert.True(t, ok, "expected DecodeErrors, got %#v", err) {
+		AssertErrorsMatch(t, []string{
+			"8:1: [error.id.duplicate] ID “twin” is already used by the prediction at 4:1",
+		}, des)
+	}
+}
+
+func TestAddMissingIDs(t *testing.T) {
+	const before = `# predictions about food
+title: Food
+---
+claim: I will eat a salad
+confidence: 60
+---
+# this one already has an ID
+id: soup
+claim: I will eat soup
+confidence: 40
+---
+{claim: I will eat a sandwich, confidence: 50}
+---
+`
+	const after = `# predictions about food
+title: Food
+---
+id: id1
+claim: I will eat a salad
+confidence: 60
+---
+# this one already has an ID
+id: soup
+claim: I will eat soup
+confidence: 40
+---
+{id: id2, claim: I will eat a sandwich, confidence: 50}
+---
+`
+
+	n := 0
+	added := 0
+	out := rewrite(t, before, func(s *Stream) {
+		added = AddMissingIDs(s, func() string {
+			n++
+			return fmt.Sprintf("id%d", n)
+		})
+	})
+
+	assert.Equal(t, 2, added)
+	assert.Equal(t, after, out)
+}
+
+// rewrite reads a stream, edits it, and writes it back out.
+func rewrite(t *testing.T, text string, edit func(s *Stream)) string {
+	s, err := FromReader(strings.NewReader(text))
+	assert.NoError(t, err)
+
+	edit(&s)
+
+	var buf strings.Builder
+	assert.NoError(t, ToWriter(&buf, s))
+	return buf.String()
+}
+
+const updatedJob = `---
+title: Updates
+---
+claim: I will get a new job
+confidence: 60
+made on: 2019-01-01
+resolved on: 2019-01-21
+happened: true
+updates:
+  - on: 2019-01-11
+    confidence: 80
+    note: second interview went well
+---
+claim: I will get a raise
+confidence: 50
+updates:
+  - confidence: 30
+  - on: 2019-01-01
+---
+claim: I will get promoted
+confidence: 20
+updates:
+  - confidence: 150
+`
+
+func TestUpdates(t *testing.T) {
+	const ε = 0.0001
+	s := mustStreamFromString(t, updatedJob)
+
+	job := s.Predictions[0]
+	if assert.Len(t, job.Updates, 1) {
+		assert.Equal(t, "second interview went well", job.Updates[0].Note)
+		assert.Equal(t, time.Date(2019, 1, 11, 0, 0, 0, 0, time.UTC), *job.Updates[0].On)
+	}
+
+	assert.InDelta(t, 60, *job.ConfidenceFor(InitialForecast), ε)
+	assert.InDelta(t, 80, *job.ConfidenceFor(FinalForecast), ε)
+	assert.InDelta(t, 70, *job.ConfidenceFor(TimeWeightedForecast), ε)
+
+	raise := s.Predictions[1]
+	assert.InDelta(t, 40, *raise.ConfidenceFor(TimeWeightedForecast), ε, "without dates, confidences should be weighed equally")
+
+	final := WithForecast([]Stream{s}, FinalForecast)
+	assert.InDelta(t, 80, *final[0].Predictions[0].Confidence, ε)
+	assert.InDelta(t, 60, *s.Predictions[0].Confidence, ε, "WithForecast shouldn’t touch the original")
+	assert.InDelta(t, 60, final[0].Predictions[0].ConfidenceHistory()[0].Confidence, ε)
+
+	var sv Validator
+	AssertErrorsMatch(t, []string{
+		"14:1: [error.update.confidence-missing] prediction with claim “I will get a raise” has an update with no confidence level",
+		"20:1: [error.confidence.impossible] prediction with claim “I will get promoted” has an update with a confidence level below 0% or above 100%",
+	}, sv.RunValidationFunctions(s, sv.AllUpdatesValid))
+}
+
+func TestNewStreamFile(t *testing.T) {
+	madeOn := time.Date(2019, 1, 2, 0, 0, 0, 0, time.UTC)
+	b := NewStreamFile("Predictions: 2019", "in 2019", "0123abcd", []string{"work", "friends, family", "2019"}, madeOn)
+
+	s, err := FromReader(strings.NewReader(string(b)))
+	assert.NoError(t, err)
+	assert.Equal(t, "Predictions: 2019", s.Metadata.Title)
+	assert.Equal(t, "in 2019", s.Metadata.Scope)
+	assert.Equal(t, "0123abcd", s.Metadata.Salt)
+
+	if assert.Len(t, s.Predictions, 1) {
+		assert.Equal(t, []string{"work", "friends, family", "2019"}, s.Predictions[0].Tags)
+		assert.Equal(t, madeOn, *s.Predictions[0].MadeOn)
+	}
+
+	v := Validator{Now: madeOn}
+	assert.Empty(t, v.RunAll(s))
+
+	afternoon := time.Date(2019, 1, 2, 15, 4, 5, 6, time.UTC)
+	b = NewStreamFile("Predictions: 2019", "", "", nil, afternoon)
+	assert.Contains(t, string(b), "made on: 2019-01-02\n")
+	assert.NotContains(t, string(b), "15:04")
+}
+
+func TestAppendPrediction(t *testing.T) {
+	confidence := 70.0
+	due := time.Date(2019, 12, 31, 0, 0, 0, 0, time.UTC)
+	d := PredictionDocument{
+		Claim:      "It will snow: a lot",
+		Confidence: &confidence,
+		Tags:       []string{"weather", "big, wet"},
+		Due:        &due,
+	}
+	appendIt := func(s *Stream) {
+		s.Predictions = append(s.Predictions, d)
+	}
+
+	const before = `title: Weather # no trailing newline
+---
+# left alone
+claim:   It will rain
+confidence: 60`
+	const after = `title: Weather # no trailing newline
+---
+# left alone
+claim:   It will rain
+confidence: 60
+---
+claim: 'It will snow: a lot'
+confidence: 70
+tags: [weather, "big, wet"]
+due: 2019-12-31
+`
+	assert.Equal(t, after, rewrite(t, before, appendIt))
+
+	const endsEmpty = "title: Weather\n---\n"
+	out := rewrite(t, endsEmpty, appendIt)
+	assert.True(t, strings.HasPrefix(out, endsEmpty+"claim: "))
+
+	s, err := FromReader(strings.NewReader(out))
+	assert.NoError(t, err)
+	if assert.Len(t, s.Predictions, 1) {
+		assert.Equal(t, d.Claim, s.Predictions[0].Claim)
+		assert.Equal(t, d.Tags, s.Predictions[0].Tags)
+		assert.Equal(t, due, *s.Predictions[0].Due)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	const before = `title: Jobs
+---
+claim: I will get the job # important
+confidence: 60
+happened: null # not yet
+notes: |
+  Applied in January.
+# end of the first prediction
+
+---
+claim: I will get a raise
+confidence: 30
+`
+	const after = `title: Jobs
+---
+claim: I will get the job # important
+confidence: 60
+happened: true # not yet
+notes: |
+  Applied in January.
+  Got an offer in March.
+resolved on: 2019-03-04
+# end of the first prediction
+
+---
+claim: I will get a raise
+confidence: 30
+`
+	happened := true
+	resolvedOn := time.Date(2019, 3, 4, 0, 0, 0, 0, time.UTC)
+	out := rewrite(t, before, func(s *Stream) {
+		s.Predictions[0].Resolve(Resolution{
+			Happened:   &happened,
+			Notes:      "Got an offer in March.",
+			ResolvedOn: &resolvedOn,
+		})
+	})
+	assert.Equal(t, after, out)
+
+	out = rewrite(t, out, func(s *Stream) {
+		s.Predictions[1].Resolve(Resolution{CauseForExclusion: "changed jobs: no raise possible"})
+	})
+	assert.True(t, strings.HasPrefix(out, after))
+	assert.True(t, strings.HasSuffix(out, "confidence: 30\ncause for exclusion: 'changed jobs: no raise possible'\n"))
+}
+
+func TestIsOngoing(t *testing.T) {
+	s := mustStreamFromString(t, `---
+title: Ongoing?
+---
+claim: a
+happened: true
+---
+claim: b
+confidence: 60
+---
+claim: c
+confidence: 60
+cause for exclusion: moot
+---
+claim: d
+outcomes: {x: 50, y: 50}
+happened: x
+`)
+
+	var ongoing []string
+	for _, d := range s.Predictions {
+		if d.IsOngoing() {
+			ongoing = append(ongoing, d.Claim)
+		}
+	}
+	assert.Equal(t, []string{"b"}, ongoing, "a resolved prediction with no confidence isn’t ongoing")
+}
+
+func TestToWriter(t *testing.T) {
+	for _, fn := range []string{"../sample/2018.yaml"} {
+		b, err := ioutil.ReadFile(fn)
+		assert.NoError(t, err)
+		assert.Equal(t, string(b), rewrite(t, string(b), func(*Stream) {}), fn)
+	}
+
+	const before = `title: Kinds # metadata
+metanotes: >
+  Folded, and
+  unknown.
+---
+claim: Who wins?
+outcomes:
+  Alice: 40
+  Bob: 60
+private notes: |
+  Keep this.
+---
+# untouched
+claim:    Rain
+confidence: 50
+`
+	const after = `title: Kinds # metadata
+metanotes: >
+  Folded, and
+  unknown.
+---
+claim: Who wins?
+outcomes:
+  Alice: 40
+  Bob: 60
+happened: Bob
+private notes: |
+  Keep this.
+---
+# untouched
+claim:    Rain
+confidence: 50
+---
+id: snow
+claim: Snow
+confidence: 20
+outcomes: {Lots: 30, Little: 70}
+given: {id: rain, happened: false}
+updates:
+  - on: 2019-01-02
+    confidence: 25
+    note: 'forecast: flurries'
+`
+	confidence, updated := 20.0, 25.0
+	on := time.Date(2019, 1, 2, 0, 0, 0, 0, time.UTC)
+	out := rewrite(t, before, func(s *Stream) {
+		s.Predictions[0].Resolve(Resolution{HappenedOutcome: "Bob"})
+		s.Predictions = append(s.Predictions, PredictionDocument{
+			ID:         "snow",
+			Claim:      "Snow",
+			Confidence: &confidence,
+			Given:      &Condition{ID: "rain"},
+			Outcomes:   Outcomes{{"Lots", 30}, {"Little", 70}},
+			Updates:    []Update{{On: &on, Confidence: &updated, Note: "forecast: flurries"}},
+		})
+	})
+	assert.Equal(t, after, out)
+
+	s, err := FromReader(strings.NewReader(out))
+	assert.NoError(t, err)
+	if assert.Len(t, s.Predictions, 3) {
+		assert.Equal(t, "Bob", s.Predictions[0].HappenedOutcome)
+		snow := s.Predictions[2]
+		assert.Equal(t, Condition{ID: "rain"}, Condition{ID: snow.Given.ID, Happened: snow.Given.Happened})
+		assert.Equal(t, Outcomes{{"Lots", 30}, {"Little", 70}}, snow.Outcomes)
+		assert.Equal(t, "forecast: flurries", snow.Updates[0].Note)
+	}
+}
+
+func TestToWriterKeepsLineEndings(t *testing.T) {
+	const before = "title: Ends\n---\nclaim: Rain\nconfidence: 50\n\n---\n{claim: Snow, confidence: 20}\n\n"
+	const after = "title: Ends\n---\nclaim: Rain\nconfidence: 50\nhappened: false\n\n---\n{id: snow, claim: Snow, confidence: 20}\n\n---\nclaim: Hail\nconfidence: 10\n"
+
+	happened := false
+	confidence := 10.0
+	edit := func(s *Stream) {
+		s.Predictions[0].Resolve(Resolution{Happened: &happened})
+		s.Predictions[1].ID = "snow"
+		s.Predictions = append(s.Predictions, PredictionDocument{Claim: "Hail", Confidence: &confidence})
+	}
+
+	crlf := func(s string) string { return strings.Replace(s, "\n", "\r\n", -1) }
+	assert.Equal(t, after, rewrite(t, before, edit))
+	assert.Equal(t, crlf(after), rewrite(t, crlf(before), edit))
+}
+
+func TestFormat(t *testing.T) {
+	const before = `# preamble
+title: Phones
+
+---
+# about the claim
+claim: Conditional on me getting a new phone, the new phone will be from the same manufacturer # hm
+tags:
+  - commerce
+  - phones, mostly
+happened: yes
+private notes: |
+  Keep this
+
+  exactly as it is.
+
+confidence: 40
+---
+{claim: Short, confidence: 30, hash: on}
+---
+claim: >-
+  Short but folded
+outcomes:
+  yes: 60
+  no: 40
+happened: yes
+# the end
+`
+	const after = `---
+# preamble
+title: Phones
+---
+# about the claim
+claim: >- # hm
+  Conditional on me getting a new phone, the new phone will be from the same
+  manufacturer
+confidence: 40
+tags: [commerce, "phones, mostly"]
+happened: true
+private notes: |
+  Keep this
+
+  exactly as it is.
+---
+claim: Short
+confidence: 30
+hash: true
+---
+claim: Short but folded
+outcomes:
+  yes: 60
+  no: 40
+happened: yes
+# the end
+`
+
+	out, err := Format([]byte(before))
+	assert.NoError(t, err)
+	assert.Equal(t, after, string(out))
+
+	again, err := Format(out)
+	assert.NoError(t, err)
+	assert.Equal(t, after, string(again))
+
+	sample, err := ioutil.ReadFile("../sample/2018.yaml")
+	assert.NoError(t, err)
+	formatted, err := Format(sample)
+	assert.NoError(t, err)
+	assert.Equal(t, string(sample), string(formatted))
+
+	_, err = Format([]byte("title: Broken\n---\nclaim: [\n"))
+	assert.Error(t, err)
+}
+
+func TestTagOrder(t *testing.T) {
+	withOrder := func(tags ...string) Stream {
+		return Stream{Metadata: MetadataDocument{TagOrder: tags}}
+	}
+
+	st := mustStreamFromString(t, `---
+title: Tagged
+tag order: [work, health]
+`)
+	assert.Equal(t, []string{"work", "health"}, st.Metadata.TagOrder)
+
+	assert.Equal(t, []string{"a", "b", "c", "d"}, TagOrder([]Stream{withOrder("a", "c"), withOrder("b", "c", "d")}))
+	assert.Equal(t, []string{"a", "b", "c"}, TagOrder([]Stream{withOrder("a", "c"), withOrder("a", "b")}))
+	assert.Equal(t, []string{"b", "a"}, TagOrder([]Stream{withOrder("b", "a"), withOrder("a", "b")}), "the first stream wins when two disagree")
+	assert.Empty(t, TagOrder([]Stream{withOrder(), {}}))
+}
+
+func TestNestedTags(t *testing.T) {
+	assert.Equal(t, "politics", ParentTag("politics/us"))
+	assert.Equal(t, "politics/us", ParentTag("politics/us/senate"))
+	assert.Equal(t, "", ParentTag("politics"))
+
+	assert.True(t, IsTagWithin("politics/us", "politics"))
+	assert.True(t, IsTagWithin("politics", "politics"))
+	assert.False(t, IsTagWithin("politicsish", "politics"))
+	assert.False(t, IsTagWithin("politics", "politics/us"))
+
+	st := mustStreamFromString(t, `---
+title: Nested
+---
+claim: The senate will pass the bill
+confidence: 60
+tags: [politics/us/senate]
+---
+claim: I will be on call over the holidays
+confidence: 30
+tags: [work/oncall, politics/intl]
+`)
+	sts := []Stream{st}
+	assert.Equal(t, []string{"politics", "politics/us", "politics/us/senate", "work", "work/oncall", "politics/intl"}, TagsWithAncestors(sts))
+	assert.Len(t, DocumentsMatching(sts, MatchingTag("politics")), 2)
+	assert.Len(t, DocumentsMatching(sts, MatchingTag("politics/us")), 1)
+	assert.Len(t, DocumentsMatching(sts, MatchingTag("work/oncall")), 1)
+}
+
+const queryStream = `---
+title: Queries
+scope: in 2019
+---
+id: job
+claim: I will get a new job
+confidence: 40
+tags: [work]
+happened: false
+made on: 2019-01-05
+---
+claim: I will be on call over the holidays
+confidence: 85
+tags: [work/oncall]
+---
+claim: I will run a marathon
+confidence: 80
+tags: [health]
+cause for exclusion: I broke my ankle.
+---
+claim: My next phone will be made by
+outcomes: {Apple: 70, Google: 30}
+tags: [work]
+happened: Google
+`
+
+func TestParseFilter(t *testing.T) {
+	sts := []Stream{mustStreamFromString(t, queryStream)}
+
+	claims := func(query string) []string {
+		t.Helper()
+		f, err := ParseFilter(query)
+		if !assert.NoError(t, err, query) {
+			return nil
+		}
+
+		ret := make([]string, 0)
+		for _, d := range DocumentsMatching(sts, f) {
+			ret = append(ret, d.Claim)
+		}
+		return ret
+	}
+
+	job, oncall, marathon, phone := "I will get a new job", "I will be on call over the holidays", "I will run a marathon", "My next phone will be made by"
+
+	assert.Equal(t, []string{oncall, phone}, claims(`tag:work and not tag:work/oncall and confidence>=70 or tag:work/oncall`))
+	assert.Equal(t, []string{job, phone}, claims(`tag:work and not tag:work/oncall and scope:"in 2019"`))
+	assert.Equal(t, []string{oncall, marathon}, claims(`confidence>=80 and not is:multiple-choice`))
+	assert.Equal(t, []string{oncall, marathon, phone}, claims(`NOT (id:job)`))
+	assert.Equal(t, []string{job}, claims(`happened:no or made<2019-01-01`))
+	assert.Equal(t, []string{phone}, claims(`happened:google`))
+	assert.Equal(t, []string{marathon}, claims(`is:excluded`))
+	assert.Equal(t, []string{oncall}, claims(`is:ongoing and claim:"ON CALL"`))
+	assert.Equal(t, []string{job}, claims(`made=2019-01-05 and title!=Other`))
+
+	for query, message := range map[string]string{
+		`tag:`:                "expected a value after “tag:” at position 5, but got the end of the query",
+		`tag:work oncall`:     "unexpected “oncall” at position 10; did you leave out an “and” or an “or”?",
+		`(tag:work`:           "expected “)” at position 10, but got the end of the query",
+		`color:red`:           "in “color:red” at position 1: unknown field “color”; try tag, title, scope, claim, id, series, confidence, made, due, resolved, happened, or is",
+		`tag>work`:            "in “tag>work” at position 1: “>” can only be used with confidence and dates",
+		`confidence:high`:     "in “confidence:high” at position 1: “high” isn’t a number",
+		`claim:"unterminated`: "quote at position 7 is never closed",
+	} {
+		_, err := ParseFilter(query)
+		if assert.Error(t, err, query) {
+			assert.Equal(t, message, err.Error())
+		}
+	}
+}
+
+func TestWhere(t *testing.T) {
+	sts := []Stream{mustStreamFromString(t, queryStream), mustStreamFromString(t, simpleStream)}
 
+	where := Where(sts, MatchingTag("work"))
+	assert.Len(t, where, 1, "streams with nothing left are left out")
+	assert.Len(t, where[0].Predictions, 3)
+	assert.Len(t, sts[0].Predictions, 4, "the originals are left alone")
 }
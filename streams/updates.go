@@ -0,0 +1,194 @@
+// © 2019 Nathan Galt
+//
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package streams
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// An Update is a change of confidence in a prediction after it was made.
+type Update struct {
+	On         *time.Time
+	Confidence *float64
+	Note       string
+}
+
+// MarshalYAML encodes the receiver as a mapping with “on”, “confidence”, and “note” keys, leaving out whichever ones it doesn’t have. Its date is written as a date, not a timestamp.
+func (u Update) MarshalYAML() (interface{}, error) {
+	n := &yaml.Node{Kind: yaml.MappingNode}
+	add := func(key string, value *yaml.Node) {
+		n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, value)
+	}
+
+	if u.On != nil {
+		add("on", &yaml.Node{Kind: yaml.ScalarNode, Value: formatTime(*u.On)})
+	}
+	if u.Confidence != nil {
+		add("confidence", &yaml.Node{Kind: yaml.ScalarNode, Value: formatNumber(*u.Confidence)})
+	}
+	if u.Note != "" {
+		add("note", &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: u.Note})
+	}
+	return n, nil
+}
+
+// A ForecastMode says which of a prediction’s confidences, when it has updates, should be scored.
+type ForecastMode int
+
+// Forecast modes.
+const (
+	// InitialForecast is the confidence the prediction was made with: its “confidence” value.
+	InitialForecast ForecastMode = iota
+
+	// FinalForecast is the confidence of the last update, or the initial confidence if there are no updates.
+	FinalForecast
+
+	// TimeWeightedForecast is the average of all the prediction’s confidences, each weighted by how long it was held.
+	TimeWeightedForecast
+)
+
+// ParseForecastMode turns “initial”, “final”, or “time-weighted” into a ForecastMode.
+func ParseForecastMode(s string) (ForecastMode, error) {
+	switch strings.ToLower(s) {
+	case "initial", "first":
+		return InitialForecast, nil
+	case "final", "last":
+		return FinalForecast, nil
+	case "time-weighted", "timeweighted", "average":
+		return TimeWeightedForecast, nil
+	}
+	return InitialForecast, fmt.Errorf("unknown forecast “%s”; try “initial”, “final”, or “time-weighted”", s)
+}
+
+// A ConfidencePoint is one of the confidences a prediction has had, and when it started having it. On is nil if that isn’t known.
+type ConfidencePoint struct {
+	On         *time.Time
+	Confidence float64
+}
+
+// ConfidenceHistory returns every confidence the receiver has had, oldest first: its initial confidence, then each update that has a confidence.
+func (d *PredictionDocument) ConfidenceHistory() []ConfidencePoint {
+	if d == nil {
+		return nil
+	}
+
+	initial := d.Confidence
+	if d.InitialConfidence != nil {
+		initial = d.InitialConfidence
+	}
+
+	ret := make([]ConfidencePoint, 0, len(d.Updates)+1)
+	if initial != nil {
+		ret = append(ret, ConfidencePoint{On: d.MadeOn, Confidence: *initial})
+	}
+	for _, u := range d.Updates {
+		if u.Confidence != nil {
+			ret = append(ret, ConfidencePoint{On: u.On, Confidence: *u.Confidence})
+		}
+	}
+	return ret
+}
+
+// ConfidenceFor returns the receiver’s confidence as of the given forecast mode. It returns nil if the receiver has no confidence at all.
+//
+// A time-weighted confidence weighs each confidence by how long it was held, up to when the prediction was resolved (or, failing that, when it was due). If any of those dates are missing, each confidence is weighed equally instead.
+func (d *PredictionDocument) ConfidenceFor(m ForecastMode) *float64 {
+	if d == nil {
+		return nil
+	}
+
+	if d.Confidence == nil || len(d.Updates) == 0 {
+		return d.Confidence
+	}
+
+	history := d.ConfidenceHistory()
+	switch m {
+	case InitialForecast:
+		return &history[0].Confidence
+	case FinalForecast:
+		return &history[len(history)-1].Confidence
+	case TimeWeightedForecast:
+		ret := timeWeightedAverage(history, d.endOfForecast())
+		return &ret
+	}
+	return d.Confidence
+}
+
+// endOfForecast returns when the receiver stopped being forecast: when it was resolved, or when it was due if it wasn’t resolved.
+func (d *PredictionDocument) endOfForecast() *time.Time {
+	if d.ResolvedOn != nil {
+		return d.ResolvedOn
+	}
+	return d.Due
+}
+
+func timeWeightedAverage(history []ConfidencePoint, end *time.Time) float64 {
+	var sum, total float64
+
+	datesKnown := end != nil
+	for _, p := range history {
+		datesKnown = datesKnown && p.On != nil
+	}
+
+	if datesKnown {
+		for i, p := range history {
+			until := *end
+			if i+1 < len(history) {
+				until = *history[i+1].On
+			}
+			weight := until.Sub(*p.On).Hours()
+			if weight < 0 {
+				weight = 0
+			}
+			sum += weight * p.Confidence
+			total += weight
+		}
+	}
+
+	if total == 0 {
+		sum = 0
+		for _, p := range history {
+			sum += p.Confidence
+		}
+		return sum / float64(len(history))
+	}
+
+	return sum / total
+}
+
+// WithForecast returns copies of the given Streams where every prediction’s confidence is its confidence as of the given forecast mode. The originals are left alone.
+func WithForecast(sts []Stream, m ForecastMode) []Stream {
+	if m == InitialForecast {
+		return sts
+	}
+
+	ret := make([]Stream, len(sts))
+	for i, s := range sts {
+		ret[i] = s
+		ret[i].Predictions = make([]PredictionDocument, len(s.Predictions))
+		for j, pred := range s.Predictions {
+			if len(pred.Updates) > 0 && pred.InitialConfidence == nil {
+				pred.InitialConfidence = pred.Confidence
+			}
+			pred.Confidence = pred.ConfidenceFor(m)
+			ret[i].Predictions[j] = pred
+		}
+	}
+	return ret
+}
@@ -0,0 +1,659 @@
+// © 2019 Nathan Galt
+//
+// Licensed under the Apache License, Version 2.0 (the “License”);
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an “AS IS” BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package streams
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// ToWriter writes a Stream out as YAML.
+//
+// A Stream read with FromReader or FromFiles is written back the way it was read, with only the keys whose values have changed since then rewritten. Everything else — comments, key order, block scalars, keys predictions doesn’t know about like “metanotes” and “private notes”, and every document that hasn’t changed at all — is written back byte for byte.
+//
+// A key that’s been added goes after the last key that comes before it in PredictionDocument (or MetadataDocument), or before the first key that comes after it, or after the last key. Predictions that weren’t read from the stream go at the end, each in a new document. Documents whose predictions have been taken out of the Stream are written back as they were.
+func ToWriter(w io.Writer, s Stream) error {
+	b, err := s.bytes()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// ToFile writes a Stream to a file, as ToWriter does. If the file already exists, it keeps its permissions.
+func ToFile(filename string, s Stream) error {
+	b, err := s.bytes()
+	if err != nil {
+		return errors.WithMessage(err, filename)
+	}
+
+	mode := os.FileMode(0644)
+	if fi, err := os.Stat(filename); err == nil {
+		mode = fi.Mode()
+	}
+	return ioutil.WriteFile(filename, b, mode)
+}
+
+func (s Stream) bytes() ([]byte, error) {
+	var buf bytes.Buffer
+
+	if len(s.documents) == 0 {
+		buf.WriteString("---\n")
+		if err := writeDocument(&buf, s.Metadata); err != nil {
+			return nil, err
+		}
+	}
+
+	texts := make([][]byte, len(s.raw))
+	for i, rd := range s.raw {
+		texts[i] = rd.text
+	}
+
+	edit := func(document int, is interface{}) error {
+		rd := s.raw[s.documents[document]]
+		was, err := rd.decodeAs(reflect.TypeOf(is))
+		if err != nil {
+			// It couldn’t be read, so there’s nothing to compare against; leave it alone.
+			return nil
+		}
+		text, err := editDocument(rd, was, is)
+		if err != nil {
+			return errors.WithMessagef(err, "couldn’t write the document on line %d", rd.line)
+		}
+		texts[s.documents[document]] = text
+		return nil
+	}
+
+	if len(s.documents) > 0 {
+		if err := edit(0, s.Metadata); err != nil {
+			return nil, err
+		}
+	}
+
+	var added []PredictionDocument
+	seen := make(map[int]bool)
+	for _, pred := range s.Predictions {
+		document := pred.Position.Document
+		if document < 1 || document >= len(s.documents) || seen[document] {
+			added = append(added, pred)
+			continue
+		}
+		seen[document] = true
+
+		if err := edit(document, pred); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, text := range texts {
+		buf.Write(text)
+	}
+	kept := buf.Len()
+
+	for i, pred := range added {
+		if buf.Len() > 0 && !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+			buf.WriteString("\n")
+		}
+
+		// A stream that ends with an empty document, as it does when its last line is “---”, gets its first new prediction there.
+		last := len(s.raw) - 1
+		fillLast := i == 0 && last > 0 && bytes.Equal(texts[last], s.raw[last].text) && isBlankAfterMarker(texts[last])
+		if !fillLast {
+			buf.WriteString("---\n")
+		}
+
+		if err := writeDocument(&buf, pred); err != nil {
+			return nil, err
+		}
+	}
+
+	b := buf.Bytes()
+	return append(b[:kept:kept], withLineEnding(b[kept:], lineEnding(b[:kept]))...), nil
+}
+
+// lineEnding returns the line ending that text’s first line ends with: “\r\n” or, if it doesn’t end with one (or there isn’t a whole line in text), “\n”.
+func lineEnding(text []byte) string {
+	if i := bytes.IndexByte(text, '\n'); i > 0 && text[i-1] == '\r' {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// withLineEnding returns text with every “\n” that isn’t already part of a “\r\n” replaced by eol.
+func withLineEnding(text []byte, eol string) []byte {
+	if eol == "\n" {
+		return text
+	}
+
+	var buf bytes.Buffer
+	for i, c := range text {
+		if c == '\n' && (i == 0 || text[i-1] != '\r') {
+			buf.WriteString(eol)
+			continue
+		}
+		buf.WriteByte(c)
+	}
+	return buf.Bytes()
+}
+
+// decodeAs decodes the receiver into a new value of type t, which is returned as an interface{} holding a t.
+func (rd rawDocument) decodeAs(t reflect.Type) (interface{}, error) {
+	v := reflect.New(t)
+	n, err := rd.node()
+	if err == io.EOF {
+		return v.Elem().Interface(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := n.Decode(v.Interface()); err != nil {
+		return nil, err
+	}
+	return v.Elem().Interface(), nil
+}
+
+// isBlankAfterMarker returns true if there’s nothing but whitespace in a document after its “---” line.
+func isBlankAfterMarker(text []byte) bool {
+	i := bytes.IndexByte(text, '\n')
+	if i < 0 {
+		return true
+	}
+	return len(bytes.TrimSpace(text[i+1:])) == 0
+}
+
+// A documentField is a key that a MetadataDocument or a PredictionDocument can have, along with a way to get its value from one.
+type documentField struct {
+	key   string
+	value func(v reflect.Value) interface{}
+}
+
+// happenedField is the “happened” key of a PredictionDocument. It’s decoded by hand, so it’s written by hand too.
+var happenedField = documentField{"happened", func(v reflect.Value) interface{} {
+	d := v.Interface().(PredictionDocument)
+	if d.IsCategorical() {
+		return d.HappenedOutcome
+	}
+	return d.Happened
+}}
+
+// documentFields returns the keys that documents of type t can have, in the order their fields are declared in, except that a prediction’s outcomes, range, and actual value come right after its tags, followed by “happened”, so what happened always comes right after what it happened to.
+func documentFields(t reflect.Type) []documentField {
+	ret := make([]documentField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		i := i
+
+		tag := f.Tag.Get("yaml")
+		if tag == "-" || f.Name == "Parent" || f.PkgPath != "" {
+			continue
+		}
+		key := strings.ToLower(f.Name)
+		if tag != "" {
+			key = strings.Split(tag, ",")[0]
+		}
+		ret = append(ret, documentField{key, func(v reflect.Value) interface{} {
+			return v.Field(i).Interface()
+		}})
+	}
+
+	if t != reflect.TypeOf(PredictionDocument{}) {
+		return ret
+	}
+
+	var moved, rest []documentField
+	for _, f := range ret {
+		switch f.key {
+		case "outcomes", "low", "high", "actual":
+			moved = append(moved, f)
+		default:
+			rest = append(rest, f)
+		}
+	}
+	moved = append(moved, happenedField)
+
+	ret = ret[:0]
+	for _, f := range rest {
+		ret = append(ret, f)
+		if f.key == "tags" {
+			ret = append(ret, moved...)
+		}
+	}
+	return ret
+}
+
+// writeDocument writes every key that a MetadataDocument or a PredictionDocument has a value for.
+func writeDocument(buf *bytes.Buffer, v interface{}) error {
+	for _, f := range documentFields(reflect.TypeOf(v)) {
+		value, err := renderValue(f.value(reflect.ValueOf(v)))
+		if err != nil {
+			return err
+		}
+		if value != "" {
+			buf.WriteString(keyLine("", f.key, value, ""))
+		}
+	}
+	return nil
+}
+
+// keyLine returns the line (or lines) for a key and its value, already rendered by renderValue, indented by indent.
+func keyLine(indent, key, value, comment string) string {
+	s := indent + key + ":"
+	if !strings.HasPrefix(value, "\n") {
+		s += " "
+	}
+	s += strings.Replace(value, "\n", "\n"+indent, -1)
+	if comment != "" {
+		s += " " + comment
+	}
+	return s + "\n"
+}
+
+// renderValue returns a value as it should be written after its key, or the empty string if it shouldn’t be written at all. Values that take up more than one line, like block sequences, start with a newline.
+func renderValue(x interface{}) (string, error) {
+	switch v := x.(type) {
+	case string:
+		if v == "" {
+			return "", nil
+		}
+		if strings.Contains(v, "\n") {
+			return literalBlock(v), nil
+		}
+		return yamlScalar(v, false), nil
+	case bool:
+		if !v {
+			return "", nil
+		}
+		return "true", nil
+	case *bool:
+		if v == nil {
+			return "", nil
+		}
+		return strconv.FormatBool(*v), nil
+	case *float64:
+		if v == nil {
+			return "", nil
+		}
+		return formatNumber(*v), nil
+	case *time.Time:
+		if v == nil {
+			return "", nil
+		}
+		return formatTime(*v), nil
+	case []string:
+		if len(v) == 0 {
+			return "", nil
+		}
+		quoted := make([]string, 0, len(v))
+		for _, s := range v {
+			quoted = append(quoted, yamlScalar(s, true))
+		}
+		return "[" + strings.Join(quoted, ", ") + "]", nil
+	}
+
+	rv := reflect.ValueOf(x)
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice:
+		if rv.IsNil() || rv.Kind() != reflect.Ptr && rv.Len() == 0 {
+			return "", nil
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(x); err != nil {
+		return "", err
+	}
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
+
+	var n yaml.Node
+	if err := yaml.Unmarshal(buf.Bytes(), &n); err != nil {
+		return "", err
+	}
+	s := strings.TrimSuffix(buf.String(), "\n")
+	if v := n.Content[0]; v.Kind == yaml.ScalarNode || v.Style&yaml.FlowStyle != 0 {
+		return s, nil
+	}
+	return "\n  " + strings.Replace(s, "\n", "\n  ", -1), nil
+}
+
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// formatTime returns a time as a date, like 2019-01-31, unless it’s not midnight UTC.
+func formatTime(t time.Time) string {
+	if t.Equal(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)) {
+		return t.Format("2006-01-02")
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+// yamlScalar returns s as it should be written in a YAML file: as is, if it reads back as the same string, or quoted otherwise. If inFlow is true, s is going in a flow sequence, where commas and brackets mean something too.
+func yamlScalar(s string, inFlow bool) string {
+	if inFlow && strings.ContainsAny(s, ",[]{}") {
+		return fmt.Sprintf("%q", s)
+	}
+	if readsBackAs("x: "+s, s) {
+		return s
+	}
+
+	b, err := yaml.Marshal(s)
+	quoted := strings.TrimSuffix(string(b), "\n")
+	if err != nil || strings.Contains(quoted, "\n") || !readsBackAs("x: "+quoted, s) {
+		return fmt.Sprintf("%q", s)
+	}
+	return quoted
+}
+
+// readsBackAs returns true if the YAML mapping in doc has an “x” key whose value is the string want.
+func readsBackAs(doc, want string) bool {
+	var m map[string]interface{}
+	if err := yaml.Unmarshal([]byte(doc), &m); err != nil {
+		return false
+	}
+	got, ok := m["x"].(string)
+	return ok && got == want
+}
+
+// literalBlock returns s as a literal block scalar (“|”) indented by two spaces.
+func literalBlock(s string) string {
+	var buf strings.Builder
+	buf.WriteString("|")
+	if strings.HasPrefix(s, " ") || strings.HasPrefix(s, "\n") {
+		buf.WriteString("2")
+	}
+
+	trimmed := strings.TrimRight(s, "\n")
+	switch len(s) - len(trimmed) {
+	case 0:
+		buf.WriteString("-")
+	case 1:
+	default:
+		buf.WriteString("+")
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(s, "\n"), "\n") {
+		buf.WriteString("\n")
+		if line != "" {
+			buf.WriteString("  " + line)
+		}
+	}
+	return buf.String()
+}
+
+// A change is a key whose value has changed, along with its new value as rendered by renderValue. An empty value means the key should go.
+type change struct {
+	key, value string
+}
+
+// editDocument returns the text of a document with every key whose value differs between was and is rewritten. Everything else is left exactly as it was.
+//
+// was and is must both be MetadataDocuments or both be PredictionDocuments.
+func editDocument(rd rawDocument, was, is interface{}) ([]byte, error) {
+	fields := documentFields(reflect.TypeOf(is))
+	order := make(map[string]int, len(fields))
+
+	var changes []change
+	for i, f := range fields {
+		order[f.key] = i
+
+		before, err := renderValue(f.value(reflect.ValueOf(was)))
+		if err != nil {
+			return nil, err
+		}
+		after, err := renderValue(f.value(reflect.ValueOf(is)))
+		if err != nil {
+			return nil, err
+		}
+		if before != after {
+			changes = append(changes, change{f.key, after})
+		}
+	}
+	if len(changes) == 0 {
+		return rd.text, nil
+	}
+
+	n, err := rd.node()
+	var text []byte
+	switch {
+	case err == io.EOF || err == nil && (len(n.Content) == 0 || n.Content[0].Tag == "!!null"):
+		text, err = fillEmptyDocument(rd, is)
+	case err != nil:
+		return nil, err
+	case n.Content[0].Kind != yaml.MappingNode:
+		return nil, errors.New("it isn’t a mapping")
+	case n.Content[0].Style&yaml.FlowStyle != 0:
+		text, err = editFlowDocument(rd, n, changes, order)
+	default:
+		text = rd.text
+		for _, c := range changes {
+			if text, err = setKey(rawDocument{text: text, line: rd.line}, c, order); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Lines written here end in “\n”; make them end the way the rest of the document’s lines do.
+	text = withLineEnding(text, lineEnding(rd.text))
+
+	// Make sure that what’s about to be written reads back as what it should be.
+	got, err := rawDocument{text: text, line: rd.line}.decodeAs(reflect.TypeOf(is))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range fields {
+		want, _ := renderValue(f.value(reflect.ValueOf(is)))
+		have, _ := renderValue(f.value(reflect.ValueOf(got)))
+		if want != have {
+			return nil, fmt.Errorf("“%s” wouldn’t read back the way it should", f.key)
+		}
+	}
+
+	return text, nil
+}
+
+// fillEmptyDocument writes every key of v into a document that has nothing but its “---” line, comments, and whitespace in it.
+func fillEmptyDocument(rd rawDocument, v interface{}) ([]byte, error) {
+	lines := bytes.SplitAfter(rd.text, []byte("\n"))
+
+	var buf bytes.Buffer
+	rest := lines
+	if len(lines) > 0 && isDocumentMarker(lines[0]) {
+		buf.Write(lines[0])
+		if !bytes.HasSuffix(lines[0], []byte("\n")) {
+			buf.WriteString("\n")
+		}
+		rest = lines[1:]
+	}
+
+	if err := writeDocument(&buf, v); err != nil {
+		return nil, err
+	}
+	for _, line := range rest {
+		buf.Write(line)
+	}
+	return buf.Bytes(), nil
+}
+
+// setKey makes one change to a document that’s a block mapping, touching only the lines of the key being changed.
+//
+// If the key is already there, its lines are replaced (or removed), and a comment at the end of its line is kept. If it isn’t, its line goes where insertionPoint says it should.
+func setKey(rd rawDocument, c change, order map[string]int) ([]byte, error) {
+	n, err := rd.node()
+	if err != nil {
+		return nil, err
+	}
+	m := n.Content[0]
+	indent := strings.Repeat(" ", m.Column-1)
+	lines := bytes.SplitAfter(rd.text, []byte("\n"))
+
+	var start, end int
+	comment := ""
+	if k := mappingKey(m, c.key); k != nil {
+		start = k.Line - rd.line
+		end = valueEnd(lines, start)
+		if v := mappingValue(m, c.key); v != nil && end == start+1 {
+			comment = v.LineComment
+		}
+	} else {
+		if c.value == "" {
+			return rd.text, nil
+		}
+
+		if at := insertionPoint(m, c.key, order); at == 0 {
+			start = m.Content[0].Line - rd.line
+		} else {
+			start = valueEnd(lines, m.Content[at-2].Line-rd.line)
+		}
+		end = start
+	}
+
+	var buf bytes.Buffer
+	for _, line := range lines[:start] {
+		buf.Write(line)
+	}
+	if start > 0 && !bytes.HasSuffix(lines[start-1], []byte("\n")) {
+		buf.WriteString("\n")
+	}
+	if c.value != "" {
+		buf.WriteString(keyLine(indent, c.key, c.value, comment))
+	}
+	for _, line := range lines[end:] {
+		buf.Write(line)
+	}
+	return buf.Bytes(), nil
+}
+
+// editFlowDocument makes changes to a document that’s a flow mapping (“{claim: …, confidence: …}”). There’s no way to do that line by line, so the whole document is written out again, which keeps its comments but not necessarily its spacing.
+func editFlowDocument(rd rawDocument, n *yaml.Node, changes []change, order map[string]int) ([]byte, error) {
+	m := n.Content[0]
+
+	for _, c := range changes {
+		i := 0
+		for i+1 < len(m.Content) && m.Content[i].Value != c.key {
+			i += 2
+		}
+		exists := i+1 < len(m.Content)
+
+		if c.value == "" {
+			if exists {
+				m.Content = append(m.Content[:i], m.Content[i+2:]...)
+			}
+			continue
+		}
+
+		var v yaml.Node
+		if err := yaml.Unmarshal([]byte(strings.TrimPrefix(c.value, "\n")), &v); err != nil {
+			return nil, err
+		}
+		if exists {
+			m.Content[i+1] = v.Content[0]
+			continue
+		}
+
+		at := insertionPoint(m, c.key, order)
+		k := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: c.key}
+		m.Content = append(m.Content[:at], append([]*yaml.Node{k, v.Content[0]}, m.Content[at:]...)...)
+	}
+
+	var buf bytes.Buffer
+	lines := bytes.SplitAfter(rd.text, []byte("\n"))
+	if len(lines) > 0 && isDocumentMarker(lines[0]) {
+		buf.Write(lines[0])
+	}
+
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(n); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+
+	// Keep any blank lines the document ended with.
+	trailing := bytes.Count(rd.text[len(bytes.TrimRight(rd.text, "\r\n")):], []byte("\n")) - 1
+	for i := 0; i < trailing; i++ {
+		buf.WriteString("\n")
+	}
+	return buf.Bytes(), nil
+}
+
+// insertionPoint returns where in a mapping node’s Content a new key should go: right after the value of the last key that comes before it in order, or, if there’s no such key, right before the first key that comes after it, or, if there’s no such key either, at the end.
+func insertionPoint(m *yaml.Node, key string, order map[string]int) int {
+	before, after := -1, -1
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		o, ok := order[m.Content[i].Value]
+		switch {
+		case !ok:
+		case o < order[key]:
+			before = i
+		case o > order[key] && after < 0:
+			after = i
+		}
+	}
+
+	switch {
+	case before >= 0:
+		return before + 2
+	case after >= 0:
+		return after
+	}
+	return len(m.Content)
+}
+
+// mappingKey returns the key node of the given key in a mapping node, or nil if there isn’t one.
+func mappingKey(n *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i]
+		}
+	}
+	return nil
+}
+
+// valueEnd returns the index of the first line after the value of the key on lines[start]: after any indented lines (and sequence items) that follow it, but before any blank lines at their end.
+func valueEnd(lines [][]byte, start int) int {
+	end := start + 1
+	for end < len(lines) {
+		line := lines[end]
+		continues := len(bytes.TrimSpace(line)) == 0 || line[0] == ' ' || line[0] == '\t' ||
+			(line[0] == '-' && !isDocumentMarker(line))
+		if !continues {
+			break
+		}
+		end++
+	}
+	for end > start+1 && len(bytes.TrimSpace(lines[end-1])) == 0 {
+		end--
+	}
+	return end
+}